@@ -7,6 +7,7 @@ require (
 	github.com/libgit2/git2go/v34 v34.0.0
 	github.com/rs/zerolog v1.28.0
 	github.com/urfave/cli/v2 v2.23.7
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 replace github.com/libgit2/git2go/v34 => ./git2go
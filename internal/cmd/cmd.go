@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 
 	"github.com/galgotech/fhub-track/internal/log"
 	"github.com/galgotech/fhub-track/internal/setting"
 	"github.com/galgotech/fhub-track/internal/track"
+	"github.com/galgotech/fhub-track/internal/track/object"
+	"github.com/galgotech/fhub-track/internal/track/update"
 	"github.com/urfave/cli/v2"
 )
 
@@ -22,6 +25,18 @@ type Cmd struct {
 	TrackUpdate         bool
 }
 
+// sourceFlag selects a named source of the manifest as source repository.
+func sourceFlag(setting *setting.Setting) cli.Flag {
+	return &cli.StringFlag{
+		Name:  "source",
+		Usage: "Named source repository of the manifest, instead of --src",
+		Action: func(c *cli.Context, name string) error {
+			setting.Source = name
+			return nil
+		},
+	}
+}
+
 func New(setting *setting.Setting) error {
 
 	app := &cli.App{
@@ -35,10 +50,9 @@ func New(setting *setting.Setting) error {
 		},
 		Flags: []cli.Flag{
 			&cli.PathFlag{
-				Name:     "src",
-				Aliases:  []string{"s"},
-				Usage:    "Source repository",
-				Required: true,
+				Name:    "src",
+				Aliases: []string{"s"},
+				Usage:   "Source repository, a local path or a git url fetched in a mirror cache, not needed with --source",
 				Action: func(c *cli.Context, path cli.Path) error {
 					setting.SrcRepo = path
 					return nil
@@ -54,12 +68,51 @@ func New(setting *setting.Setting) error {
 					return nil
 				},
 			},
+			sourceFlag(setting),
 		},
 		Commands: []*cli.Command{
 			{
-				Name:  "object",
-				Usage: "Track objects",
+				Name:      "object",
+				Usage:     "Track objects (folder, file or pattern like internal/diff/**/*.go)",
+				ArgsUsage: "<src_path> [dst_path]",
+				Flags: []cli.Flag{
+					sourceFlag(setting),
+					&cli.StringFlag{
+						Name:  "rev",
+						Usage: "Source revision (tag, branch or commit) to read the objects from, default HEAD",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-dirty",
+						Usage: "Track from HEAD even when the source repository has uncommitted changes",
+					},
+					&cli.BoolFlag{
+						Name:  "with-history",
+						Usage: "Merge the source history of the objects rewritten to the destination paths",
+					},
+					&cli.StringSliceFlag{
+						Name:  "import",
+						Usage: "Go import path rewrite rule \"<from> => <to>\", kept in the manifest",
+					},
+					&cli.BoolFlag{
+						Name:  "follow-new-files",
+						Usage: "Track on update the files added in the source under the tracked folder, default only for patterns",
+					},
+					&cli.StringFlag{
+						Name:  "go-deps",
+						Usage: "Go packages of the source module imported by the tracked package: \"list\" them or \"track\" them too",
+					},
+					&cli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Pattern of files to skip, relative to the tracked folder (e.g. **/*_test.go, testdata/**)",
+					},
+				},
 				Action: func(c *cli.Context) error {
+					var followNewFiles *bool
+					if c.IsSet("follow-new-files") {
+						follow := c.Bool("follow-new-files")
+						followNewFiles = &follow
+					}
+
 					var arg1, arg2 string
 					if c.NArg() == 1 {
 						arg1 = c.Args().Get(0)
@@ -68,12 +121,47 @@ func New(setting *setting.Setting) error {
 						arg1 = c.Args().Get(0)
 						arg2 = c.Args().Get(1)
 					}
-					return track.Object(setting, arg1, arg2)
+					return track.Object(setting, arg1, arg2, object.Options{
+						Rev:            c.String("rev"),
+						Exclude:        c.StringSlice("exclude"),
+						AllowDirty:     c.Bool("allow-dirty"),
+						WithHistory:    c.Bool("with-history"),
+						Imports:        c.StringSlice("import"),
+						GoDeps:         object.GoDepsMode(c.String("go-deps")),
+						FollowNewFiles: followNewFiles,
+					})
+				},
+			},
+			{
+				Name:      "extract-package",
+				Usage:     "Track a Go package, with the internal packages it imports, in a public path",
+				ArgsUsage: "<src_package_path> <dst_path>",
+				Flags: []cli.Flag{
+					sourceFlag(setting),
+					&cli.BoolFlag{
+						Name:  "allow-dirty",
+						Usage: "Track from HEAD even when the source repository has uncommitted changes",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 2 {
+						return cli.ShowSubcommandHelp(c)
+					}
+					return track.ExtractPackage(setting, c.Args().Get(0), c.Args().Get(1), object.Options{
+						AllowDirty: c.Bool("allow-dirty"),
+					})
+				},
+			},
+			{
+				Name:  "migrate",
+				Usage: "Build the tracking manifest from the fhub-track commit history",
+				Action: func(c *cli.Context) error {
+					return track.Migrate(setting)
 				},
 			},
 			{
 				Name:  "rename",
-				Usage: "rename objects (folder ou file) <old_path> <new_path>",
+				Usage: "rename objects (folder ou file) <old_path> <new_path>, relative to the destination repository",
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 2 {
 						old := c.Args().Get(0)
@@ -85,16 +173,114 @@ func New(setting *setting.Setting) error {
 			},
 			{
 				Name:  "status",
-				Usage: "Objects status",
+				Usage: "Objects status of the source, the unnamed one by default",
+				Flags: []cli.Flag{
+					sourceFlag(setting),
+				},
 				Action: func(c *cli.Context) error {
-					return nil
+					return track.Status(setting)
+				},
+			},
+			{
+				Name:  "source",
+				Usage: "Named source repositories of the destination",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "add",
+						Usage:     "Add or change a named source repository",
+						ArgsUsage: "<name> <path>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 2 {
+								return cli.ShowSubcommandHelp(c)
+							}
+							return track.SourceAdd(setting, c.Args().Get(0), c.Args().Get(1))
+						},
+					},
+					{
+						Name:  "list",
+						Usage: "List the named source repositories",
+						Action: func(c *cli.Context) error {
+							return track.SourceList(setting)
+						},
+					},
+				},
+			},
+			{
+				Name:      "untrack",
+				Usage:     "Stop tracking objects (folder or file) <dst_path>",
+				ArgsUsage: "<dst_path>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "delete",
+						Usage: "Delete the objects from the destination repository",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return cli.ShowSubcommandHelp(c)
+					}
+					return track.Untrack(setting, c.Args().Get(0), c.Bool("delete"))
 				},
 			},
 			{
 				Name:  "update",
 				Usage: "Update object",
+				Flags: []cli.Flag{
+					sourceFlag(setting),
+					&cli.StringFlag{
+						Name:  "renames",
+						Usage: "Objects renamed in the source: \"keep\" the destination path or \"follow\" the source rename",
+						Value: string(update.RenameKeep),
+					},
+					&cli.StringFlag{
+						Name:  "deletes",
+						Usage: "Objects deleted in the source and modified in the destination: \"keep\" and untrack them, \"delete\" them or \"stop\" with a conflict",
+						Value: string(update.DeleteStop),
+					},
+					&cli.BoolFlag{
+						Name:  "no-commit",
+						Usage: "Leave the update staged instead of committing it",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Source revision or date (YYYY-MM-DD) to update to, default HEAD",
+					},
+					&cli.BoolFlag{
+						Name:  "replay",
+						Usage: "Apply every source commit that changed tracked objects as its own commit",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the update as a diff against the destination work tree without changing it",
+					},
+					&cli.BoolFlag{
+						Name:  "continue",
+						Usage: "Commit an update stopped by conflicts once they are resolved and staged",
+					},
+					&cli.BoolFlag{
+						Name:  "abort",
+						Usage: "Abort an update stopped by conflicts and restore the destination",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					return track.Update(setting)
+					options := update.Options{
+						Renames:  update.RenamePolicy(c.String("renames")),
+						Deletes:  update.DeletePolicy(c.String("deletes")),
+						NoCommit: c.Bool("no-commit"),
+						To:       c.String("to"),
+						Replay:   c.Bool("replay"),
+						DryRun:   c.Bool("dry-run"),
+					}
+					if c.Bool("continue") && c.Bool("abort") {
+						return errors.New("--continue and --abort can't be used together")
+					}
+					if c.Bool("continue") {
+						return track.UpdateContinue(setting, options)
+					}
+					if c.Bool("abort") {
+						return track.UpdateAbort(setting)
+					}
+					return track.Update(setting, options)
 				},
 			},
 		},
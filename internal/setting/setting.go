@@ -8,6 +8,9 @@ type Setting struct {
 	RootPath string
 	SrcRepo  string
 	DstRepo  string
+	// Source is the name of a src repository of the dst manifest, used
+	// instead of SrcRepo
+	Source string
 }
 
 func (s *Setting) Init() error {
@@ -0,0 +1,121 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/galgotech/fhub-track/internal/log"
+	"github.com/galgotech/fhub-track/internal/track/godeps"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/object"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+type Extract struct {
+	src, dst *git.Repository
+}
+
+func New(src, dst *git.Repository) *Extract {
+	return &Extract{src, dst}
+}
+
+var logTrack = log.New("track-extract")
+
+// Run tracks the Go package of the src folder srcPkg in the dst folder dstPkg,
+// with the internal packages of the src module it imports. The internal
+// packages out of srcPkg are tracked in the internal folder of dstPkg, and the
+// imports are rewritten to the dst module. Only the files of each package are
+// tracked, not its sub folders nor its tests. Nothing is tracked when a package can't be
+// extracted. The packages are tracked with options, and the import rules of
+// the extraction.
+func (t *Extract) Run(srcPkg, dstPkg string, options object.Options) error {
+	srcPkg = filepath.Clean(srcPkg)
+	dstPkg = filepath.Clean(dstPkg)
+
+	logTrack.Info("start extract package", "src", srcPkg, "dst", dstPkg)
+
+	commit, err := utils.HeadCommit(t.src)
+	if err != nil {
+		return err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.dst.Workdir(), "go.mod"))
+	if err != nil {
+		return fmt.Errorf("the destination repository has no go.mod: %w", err)
+	}
+	dstModule, err := godeps.ModulePath(data)
+	if err != nil {
+		return err
+	}
+
+	deps, err := godeps.Closure(t.src, tree, srcPkg, godeps.IsInternal)
+	if err != nil {
+		return err
+	}
+	if len(deps.Issues) > 0 {
+		return fmt.Errorf("package '%s' can't be extracted:\n  %s", srcPkg, strings.Join(deps.Issues, "\n  "))
+	}
+
+	// Packages inside a tracked folder keep their place in its dst folder,
+	// the sorted packages are mapped after the folders holding them
+	tracked := map[string]string{srcPkg: dstPkg}
+	for _, pkg := range deps.Packages {
+		tracked[pkg] = filepath.Join(dstPkg, "internal", internalName(pkg))
+		parent := ""
+		for dir := range tracked {
+			if dir != pkg && manifest.IsSubPath(dir, pkg) && len(dir) > len(parent) {
+				parent = dir
+			}
+		}
+		if parent != "" {
+			rel, err := filepath.Rel(parent, pkg)
+			if err != nil {
+				return err
+			}
+			tracked[pkg] = filepath.Join(tracked[parent], rel)
+		}
+	}
+
+	dirs := []string{srcPkg}
+	imports := []string{}
+	for dir, dstDir := range tracked {
+		if dir != srcPkg {
+			dirs = append(dirs, dir)
+		}
+		imports = append(imports, fmt.Sprintf("%s => %s", godeps.ImportPath(deps.Module, dir), godeps.ImportPath(dstModule, dstDir)))
+	}
+	sort.Strings(dirs[1:])
+	sort.Strings(imports)
+
+	exclude := options.Exclude
+	for _, dir := range dirs {
+		logTrack.Info("extract", "src", dir, "dst", tracked[dir])
+		options.Imports = imports
+		options.Exclude = append(append([]string{}, exclude...), godeps.TestFiles)
+		err := object.New(t.src, t.dst).Run(filepath.Join(dir, "*"), tracked[dir], options)
+		if err != nil {
+			return err
+		}
+	}
+
+	logTrack.Info("package extracted", "packages", len(dirs), "module", dstModule)
+	return nil
+}
+
+// internalName is the path of an internal package after its last internal
+// folder.
+func internalName(dir string) string {
+	if i := strings.LastIndex(dir, "internal/"); i >= 0 && (i == 0 || dir[i-1] == '/') {
+		return dir[i+len("internal/"):]
+	}
+	return filepath.Base(dir)
+}
@@ -0,0 +1,208 @@
+package godeps
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// TestFiles is the exclude pattern of the Go test files, their imports are
+// left out of the closure.
+const TestFiles = "*_test.go"
+
+// Deps is the dependency closure of a Go package in a module tree.
+type Deps struct {
+	Module string
+	// Packages are the folders of the module packages imported, directly or
+	// not, sorted
+	Packages []string
+	// External are the imports of other modules, the standard library is
+	// left out
+	External []string
+	// Issues are the imports and directives that can't be followed in the
+	// module
+	Issues []string
+}
+
+// Closure walks the imports of the pkg folder in tree. The imports of the
+// module matching follow, every one when follow is nil, are walked.
+func Closure(repo *git.Repository, tree *git.Tree, pkg string, follow func(importPath string) bool) (*Deps, error) {
+	entry, err := tree.EntryByPath("go.mod")
+	if err != nil {
+		return nil, errors.New("the source repository has no go.mod")
+	}
+	blob, err := repo.LookupBlob(entry.Id)
+	if err != nil {
+		return nil, err
+	}
+	module, err := ModulePath(blob.Contents())
+	if err != nil {
+		return nil, err
+	}
+
+	deps := &Deps{Module: module}
+	external := map[string]bool{}
+	visited := map[string]bool{pkg: true}
+	queue := []string{pkg}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		files, err := goFiles(repo, tree, dir)
+		if err != nil || len(files) == 0 {
+			deps.Issues = append(deps.Issues, fmt.Sprintf("%s: Go package not found", dir))
+			continue
+		}
+
+		for _, path := range files {
+			entry, err := tree.EntryByPath(path)
+			if err != nil {
+				return nil, err
+			}
+			blob, err := repo.LookupBlob(entry.Id)
+			if err != nil {
+				return nil, err
+			}
+
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, path, blob.Contents(), parser.ParseComments)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, group := range file.Comments {
+				for _, comment := range group.List {
+					if strings.HasPrefix(comment.Text, "//go:linkname ") {
+						deps.Issues = append(deps.Issues, fmt.Sprintf("%s: uses go:linkname", fset.Position(comment.Pos())))
+					}
+				}
+			}
+
+			for _, spec := range file.Imports {
+				importPath, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				if importPath != module && !strings.HasPrefix(importPath, module+"/") {
+					if IsInternal(importPath) && !IsStd(importPath) {
+						deps.Issues = append(deps.Issues, fmt.Sprintf("%s: imports '%s', an internal package of another module", path, importPath))
+					} else if !IsStd(importPath) {
+						external[importPath] = true
+					}
+					continue
+				}
+
+				if follow != nil && !follow(importPath) {
+					continue
+				}
+
+				dep := strings.TrimPrefix(strings.TrimPrefix(importPath, module), "/")
+				if dep == "" {
+					dep = "."
+				}
+				if !visited[dep] {
+					visited[dep] = true
+					deps.Packages = append(deps.Packages, dep)
+					queue = append(queue, dep)
+				}
+			}
+		}
+	}
+
+	for importPath := range external {
+		deps.External = append(deps.External, importPath)
+	}
+	sort.Strings(deps.Packages)
+	sort.Strings(deps.External)
+
+	return deps, nil
+}
+
+// goFiles lists the Go files of the dir package built for the default
+// platform, the sub folders are other packages. Test files and files left out
+// by build constraints are skipped.
+func goFiles(repo *git.Repository, tree *git.Tree, dir string) ([]string, error) {
+	objects, err := utils.TreeObjects(tree, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The build constraints are read from tree, not from the work tree
+	ctxt := build.Default
+	ctxt.JoinPath = filepath.Join
+	ctxt.OpenFile = func(path string) (io.ReadCloser, error) {
+		entry, err := tree.EntryByPath(path)
+		if err != nil {
+			return nil, err
+		}
+		blob, err := repo.LookupBlob(entry.Id)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(blob.Contents())), nil
+	}
+
+	files := []string{}
+	for _, object := range objects {
+		if filepath.Dir(object) != dir || filepath.Ext(object) != ".go" || strings.HasSuffix(object, "_test.go") {
+			continue
+		}
+
+		match, err := ctxt.MatchFile(dir, filepath.Base(object))
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			files = append(files, object)
+		}
+	}
+	return files, nil
+}
+
+// ModulePath reads the module path of a go.mod file.
+func ModulePath(gomod []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(gomod))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\""), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("module path not found in go.mod")
+}
+
+// ImportPath is the import path of the dir package of module.
+func ImportPath(module, dir string) string {
+	if dir == "." {
+		return module
+	}
+	return module + "/" + filepath.ToSlash(dir)
+}
+
+func IsInternal(importPath string) bool {
+	return importPath == "internal" || strings.HasPrefix(importPath, "internal/") ||
+		strings.HasSuffix(importPath, "/internal") || strings.Contains(importPath, "/internal/")
+}
+
+// IsStd reports whether the import path is of the standard library, its
+// first element has no dot.
+func IsStd(importPath string) bool {
+	first := strings.SplitN(importPath, "/", 2)[0]
+	return !strings.Contains(first, ".")
+}
@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Import is a rewrite rule of Go import paths. The From path, and the paths
+// inside it, are replaced by To in the tracked Go files.
+type Import struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// ParseImport parses a rule written as "<from> => <to>".
+func ParseImport(rule string) (*Import, error) {
+	paths := strings.Split(rule, "=>")
+	if len(paths) != 2 {
+		return nil, fmt.Errorf("invalid import rule '%s', expected '<from> => <to>'", rule)
+	}
+
+	from := strings.TrimSpace(paths[0])
+	to := strings.TrimSpace(paths[1])
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("invalid import rule '%s', expected '<from> => <to>'", rule)
+	}
+
+	return &Import{From: from, To: to}, nil
+}
+
+// Rewrite maps an import path by the rule.
+func (i *Import) Rewrite(path string) (string, bool) {
+	if path == i.From {
+		return i.To, true
+	}
+	if strings.HasPrefix(path, i.From+"/") {
+		return i.To + path[len(i.From):], true
+	}
+	return path, false
+}
+
+// AddImport inserts the rule or replaces the one with the same From path.
+func (m *Manifest) AddImport(rule *Import) {
+	for i, r := range m.Imports {
+		if r.From == rule.From {
+			m.Imports[i] = rule
+			return
+		}
+	}
+	m.Imports = append(m.Imports, rule)
+}
@@ -0,0 +1,232 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/galgotech/fhub-track/internal/log"
+	git "github.com/libgit2/git2go/v34"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the manifest path relative to the dst work tree.
+const FileName = ".fhub-track.yaml"
+
+const Version = 1
+
+var logTrack = log.New("track-manifest")
+
+type Manifest struct {
+	Version int `yaml:"version"`
+	// Sources are the named src repositories, objects without source are
+	// tracked from the src given by path
+	Sources []*Source `yaml:"sources,omitempty"`
+	Tracks  []*Track  `yaml:"tracks,omitempty"`
+	Objects []*Object `yaml:"objects"`
+	// Untracked dst paths, files or folders, are no longer followed
+	Untracked []string `yaml:"untracked,omitempty"`
+	// Imports rewrite the Go import paths of the tracked files
+	Imports []*Import `yaml:"imports,omitempty"`
+	// Transforms is the pipeline of edits applied to the tracked files
+	Transforms []*Transform `yaml:"transforms,omitempty"`
+}
+
+// Object is a tracked file. Commit and Blob are the src baseline of the
+// last synchronization between src and dst, before any transformation.
+type Object struct {
+	Src    string `yaml:"src"`
+	Dst    string `yaml:"dst"`
+	Repo   string `yaml:"repo"`
+	Commit string `yaml:"commit"`
+	Blob   string `yaml:"blob"`
+	// Source is the name of the src repository, empty for the unnamed one
+	Source string `yaml:"source,omitempty"`
+	// Mode is the octal git file mode of the baseline blob
+	Mode string `yaml:"mode,omitempty"`
+}
+
+// FileMode parses Mode, zero when it is unknown.
+func (o *Object) FileMode() git.Filemode {
+	mode, err := strconv.ParseUint(o.Mode, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return git.Filemode(mode)
+}
+
+func FormatMode(mode git.Filemode) string {
+	return fmt.Sprintf("%06o", mode)
+}
+
+func New() *Manifest {
+	return &Manifest{
+		Version: Version,
+		Objects: []*Object{},
+	}
+}
+
+// Load reads the manifest from the dst work tree. Repositories tracked before
+// the manifest existed are migrated from the fhub-track commit history.
+func Load(src, dst *git.Repository) (*Manifest, error) {
+	m, err := Read(dst)
+	if errors.Is(err, os.ErrNotExist) {
+		logTrack.Info("manifest not found, migrate from history", "file", FileName)
+		return Migrate(src, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func Read(dst *git.Repository) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dst.Workdir(), FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	m := New()
+	err = yaml.Unmarshal(data, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Version > Version {
+		return nil, errors.New("manifest version not supported")
+	}
+
+	for i, rule := range m.Transforms {
+		err := rule.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("transform %d of %s: %w", i+1, FileName, err)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Manifest) Write(dst *git.Repository) error {
+	sort.Slice(m.Objects, func(i, j int) bool {
+		return m.Objects[i].Dst < m.Objects[j].Dst
+	})
+
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dst.Workdir(), FileName), data, 0644)
+}
+
+func (m *Manifest) Find(dst string) *Object {
+	dst = filepath.Clean(dst)
+	for _, object := range m.Objects {
+		if object.Dst == dst {
+			return object
+		}
+	}
+	return nil
+}
+
+// Add inserts the object or replaces the one with the same dst path. The
+// untracked paths holding the object are tracked again.
+func (m *Manifest) Add(object *Object) {
+	object.Src = filepath.Clean(object.Src)
+	object.Dst = filepath.Clean(object.Dst)
+	untracked := []string{}
+	for _, path := range m.Untracked {
+		if !IsSubPath(path, object.Dst) {
+			untracked = append(untracked, path)
+		}
+	}
+	m.Untracked = untracked
+	for i, o := range m.Objects {
+		if o.Dst == object.Dst {
+			m.Objects[i] = object
+			return
+		}
+	}
+	m.Objects = append(m.Objects, object)
+}
+
+func (m *Manifest) Remove(dst string) bool {
+	dst = filepath.Clean(dst)
+	for i, object := range m.Objects {
+		if object.Dst == dst {
+			m.Objects = append(m.Objects[:i], m.Objects[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Untrack removes the objects in dst, a file or a folder, and records the
+// path as untracked. The removed objects are returned.
+func (m *Manifest) Untrack(dst string) []*Object {
+	dst = filepath.Clean(dst)
+
+	removed := []*Object{}
+	objects := []*Object{}
+	for _, object := range m.Objects {
+		if IsSubPath(dst, object.Dst) {
+			removed = append(removed, object)
+		} else {
+			objects = append(objects, object)
+		}
+	}
+	m.Objects = objects
+
+	if len(removed) > 0 && !m.IsUntracked(dst) {
+		m.Untracked = append(m.Untracked, dst)
+		sort.Strings(m.Untracked)
+	}
+
+	return removed
+}
+
+func (m *Manifest) IsUntracked(dst string) bool {
+	for _, path := range m.Untracked {
+		if IsSubPath(path, dst) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubPath reports whether path is inside the parent folder or is parent.
+func IsSubPath(parent, path string) bool {
+	if parent == "." {
+		return true
+	}
+	return path == parent || strings.HasPrefix(path, parent+"/")
+}
+
+// Rename moves the dst path, a file or a folder, of every object, tracking
+// rule and untracked path inside it.
+func (m *Manifest) Rename(oldDst, newDst string) {
+	oldDst = filepath.Clean(oldDst)
+	newDst = filepath.Clean(newDst)
+
+	rename := func(path string) string {
+		if !IsSubPath(oldDst, path) {
+			return path
+		}
+		return filepath.Join(newDst, strings.TrimPrefix(path, oldDst))
+	}
+
+	for _, object := range m.Objects {
+		object.Dst = rename(object.Dst)
+	}
+	for _, track := range m.Tracks {
+		track.Dst = rename(track.Dst)
+	}
+	for i, path := range m.Untracked {
+		m.Untracked[i] = rename(path)
+	}
+}
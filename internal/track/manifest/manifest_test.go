@@ -0,0 +1,29 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddUntracked(t *testing.T) {
+	tests := []struct {
+		untracked []string
+		dst       string
+		want      []string
+	}{
+		{[]string{"pkg"}, "pkg", []string{}},
+		{[]string{"pkg"}, "pkg/main.go", []string{}},
+		{[]string{"pkg/main.go"}, "pkg/main.go", []string{}},
+		{[]string{"pkg/sub"}, "pkg/main.go", []string{"pkg/sub"}},
+		{[]string{"pkgs", "pkg"}, "pkg/main.go", []string{"pkgs"}},
+	}
+
+	for _, test := range tests {
+		m := New()
+		m.Untracked = append([]string{}, test.untracked...)
+		m.Add(&Object{Src: test.dst, Dst: test.dst})
+		if !reflect.DeepEqual(m.Untracked, test.want) {
+			t.Errorf("Add(%q) with untracked %q left %q, want %q", test.dst, test.untracked, m.Untracked, test.want)
+		}
+	}
+}
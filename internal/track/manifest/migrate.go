@@ -0,0 +1,224 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// Migrate builds the manifest from the messages written by utils.Commit,
+// walking the dst history from HEAD. The newest record of a path wins. A dst
+// without commits has an empty manifest.
+func Migrate(src, dst *git.Repository) (*Manifest, error) {
+	m := New()
+
+	head, err := dst.Head()
+	if git.IsErrorCode(err, git.ErrorCodeUnbornBranch) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := dst.LookupCommit(head.Target())
+	if err != nil {
+		return nil, err
+	}
+
+	renames := map[string]string{}
+	deleted := map[string]bool{}
+	visited := map[string]bool{}
+	stackCommit := []*git.Commit{commit}
+	for len(stackCommit) > 0 {
+		commit := stackCommit[0]
+		stackCommit = stackCommit[1:]
+		if visited[commit.Id().String()] {
+			continue
+		}
+		visited[commit.Id().String()] = true
+
+		err = m.commitIter(renames, deleted, commit)
+		if err != nil {
+			return nil, err
+		}
+
+		stackCommit = append(stackCommit, utils.CommitParents(commit)...)
+	}
+
+	err = resolveBaseline(src, m.Objects)
+	if err != nil {
+		return nil, err
+	}
+
+	// The repo lines of older histories list the dst remotes, the objects
+	// are tracked from src
+	repo, err := utils.RepoUrl(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range m.Objects {
+		object.Repo = repo
+	}
+
+	logTrack.Debug("migrate", "objects", len(m.Objects))
+
+	return m, nil
+}
+
+func (m *Manifest) commitIter(renames map[string]string, deleted map[string]bool, commit *git.Commit) error {
+	lines := strings.Split(strings.TrimSpace(commit.Message()), "\n")
+	if len(lines) < 3 || lines[0] != "fhub-track" || lines[1] != "" {
+		return nil
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	var hash string
+	lastKey := ""
+	for _, line := range lines[2:] {
+		line = strings.TrimSpace(line)
+
+		if key, ok := parseMessageKey(line); ok {
+			lastKey = key
+		} else if lastKey == "hash" {
+			hash = line
+		} else if lastKey == "files" {
+			path := strings.Split(line, ":")
+			if len(path) != 2 {
+				return fmt.Errorf("invalid line '%s'", line)
+			}
+
+			dstPath := renamed(renames, path[1])
+			if m.Find(dstPath) != nil || m.IsUntracked(dstPath) || deleted[dstPath] {
+				continue
+			}
+
+			entry, err := tree.EntryByPath(path[1])
+			if err != nil {
+				logTrack.Warn("object not found in commit", "path", path[1], "commit", commit.Id().String())
+				continue
+			}
+
+			m.Add(&Object{
+				Src:    path[0],
+				Dst:    dstPath,
+				Commit: hash,
+				Blob:   entry.Id.String(),
+				Mode:   FormatMode(entry.Filemode),
+			})
+		} else if lastKey == "rename" {
+			path := strings.Split(line, " -> ")
+			if len(path) != 2 {
+				return fmt.Errorf("invalid line '%s'", line)
+			}
+
+			// The newest rename of a path wins
+			if _, ok := renames[path[0]]; !ok {
+				renames[path[0]] = path[1]
+			}
+		} else if lastKey == "delete" {
+			// Deleted in src, the older records of the path are skipped
+			deleted[renamed(renames, line)] = true
+		} else if lastKey == "untrack" {
+			path := renamed(renames, line)
+			if !m.IsUntracked(path) {
+				m.Untracked = append(m.Untracked, path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// renamed follows the rename records of path, including renames of any of
+// its parent folders.
+func renamed(renames map[string]string, path string) string {
+	for i := 0; i <= len(renames); i++ {
+		found := false
+		for old, new := range renames {
+			if path == old || strings.HasPrefix(path, old+"/") {
+				path = new + path[len(old):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return path
+}
+
+// resolveBaseline checks the baseline commit of every object in src. Older
+// histories recorded the dst head as hash, in that case the newest src commit
+// with the same blob in the src path is used.
+func resolveBaseline(src *git.Repository, objects []*Object) error {
+	pending := []*Object{}
+	for _, object := range objects {
+		oid, err := git.NewOid(object.Commit)
+		if err == nil {
+			_, err = src.LookupCommit(oid)
+		}
+		if err != nil {
+			pending = append(pending, object)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	walk, err := src.Walk()
+	if err != nil {
+		return err
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTime)
+	err = walk.PushHead()
+	if err != nil {
+		return err
+	}
+
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		tree, err := commit.Tree()
+		if err != nil {
+			return false
+		}
+
+		remaining := []*Object{}
+		for _, object := range pending {
+			entry, err := tree.EntryByPath(object.Src)
+			if err == nil && entry.Id.String() == object.Blob {
+				object.Commit = commit.Id().String()
+			} else {
+				remaining = append(remaining, object)
+			}
+		}
+		pending = remaining
+
+		return len(pending) > 0
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, object := range pending {
+		logTrack.Warn("baseline commit not found in src", "src", object.Src, "dst", object.Dst)
+		object.Commit = ""
+	}
+
+	return nil
+}
+
+func parseMessageKey(line string) (string, bool) {
+	if line == "repo:" || line == "hash:" || line == "files:" || line == "rename:" || line == "untrack:" || line == "delete:" || line == "source:" {
+		return line[:len(line)-1], true
+	}
+	return "", false
+}
@@ -0,0 +1,28 @@
+package manifest
+
+// Source is a named src repository. Path is a git url, or a path relative to
+// the dst work tree unless it is absolute.
+type Source struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+}
+
+func (m *Manifest) FindSource(name string) *Source {
+	for _, source := range m.Sources {
+		if source.Name == name {
+			return source
+		}
+	}
+	return nil
+}
+
+// AddSource inserts the source or replaces the one with the same name.
+func (m *Manifest) AddSource(source *Source) {
+	for i, s := range m.Sources {
+		if s.Name == source.Name {
+			m.Sources[i] = source
+			return
+		}
+	}
+	m.Sources = append(m.Sources, source)
+}
@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/galgotech/fhub-track/internal/track/utils"
+)
+
+// Track is a rule of the object command. Include and Exclude are shell
+// patterns relative to Src where "**" matches any number of folders. An
+// Exclude pattern without "/" matches the file name in any folder.
+type Track struct {
+	Src     string   `yaml:"src"`
+	Dst     string   `yaml:"dst"`
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+	// FollowNewFiles tracks the files added in src under the rule on update,
+	// by default only rules with include patterns follow them
+	FollowNewFiles *bool `yaml:"follow-new-files,omitempty"`
+	// Source is the name of the src repository, empty for the unnamed one
+	Source string `yaml:"source,omitempty"`
+}
+
+// Match reports whether the src path is selected by the rule.
+func (t *Track) Match(src string) bool {
+	if !IsSubPath(t.Src, src) {
+		return false
+	}
+
+	rel, err := filepath.Rel(t.Src, src)
+	if err != nil {
+		return false
+	}
+
+	if len(t.Include) > 0 && !matchAny(t.Include, rel) {
+		return false
+	}
+
+	return !matchName(t.Exclude, rel)
+}
+
+// FollowsNewFiles reports whether update tracks the files added under the
+// rule.
+func (t *Track) FollowsNewFiles() bool {
+	if t.FollowNewFiles != nil {
+		return *t.FollowNewFiles
+	}
+	return len(t.Include) > 0
+}
+
+// DstPath maps a src path of the rule to its dst path.
+func (t *Track) DstPath(src string) string {
+	rel, err := filepath.Rel(t.Src, src)
+	if err != nil || rel == "." {
+		return t.Dst
+	}
+	return filepath.Join(t.Dst, rel)
+}
+
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if utils.MatchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchName is matchAny where a pattern without "/" matches the file name in
+// any folder.
+func matchName(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		if utils.MatchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTrack inserts the rule or replaces the one with the same source, src and
+// dst.
+func (m *Manifest) AddTrack(track *Track) {
+	track.Src = filepath.Clean(track.Src)
+	track.Dst = filepath.Clean(track.Dst)
+	for i, t := range m.Tracks {
+		if t.Source == track.Source && t.Src == track.Src && t.Dst == track.Dst {
+			m.Tracks[i] = track
+			return
+		}
+	}
+	m.Tracks = append(m.Tracks, track)
+}
+
+// FindSrc returns the objects tracked from the src path.
+func (m *Manifest) FindSrc(src string) []*Object {
+	src = filepath.Clean(src)
+	objects := []*Object{}
+	for _, object := range m.Objects {
+		if object.Src == src {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
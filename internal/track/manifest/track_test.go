@@ -0,0 +1,36 @@
+package manifest
+
+import "testing"
+
+func TestTrackMatch(t *testing.T) {
+	tests := []struct {
+		track *Track
+		src   string
+		match bool
+	}{
+		{&Track{Src: "pkg"}, "pkg/main.go", true},
+		{&Track{Src: "pkg"}, "pkg/sub/main.go", true},
+		{&Track{Src: "pkg"}, "other/main.go", false},
+		{&Track{Src: "pkg"}, "pkgs/main.go", false},
+		{&Track{Src: "pkg", Include: []string{"*.go"}}, "pkg/main.go", true},
+		{&Track{Src: "pkg", Include: []string{"*.go"}}, "pkg/sub/main.go", false},
+		{&Track{Src: "pkg", Include: []string{"**/*.go"}}, "pkg/sub/main.go", true},
+		{&Track{Src: "pkg", Include: []string{"*.go"}}, "pkg/README.md", false},
+		{&Track{Src: "pkg", Exclude: []string{"*_test.go"}}, "pkg/main_test.go", false},
+		{&Track{Src: "pkg", Exclude: []string{"*_test.go"}}, "pkg/sub/main_test.go", false},
+		{&Track{Src: "pkg", Exclude: []string{"sub/*.go"}}, "pkg/sub/main.go", false},
+		{&Track{Src: "pkg", Exclude: []string{"sub/*.go"}}, "pkg/main.go", true},
+		{&Track{Src: "pkg", Include: []string{"*"}}, "pkg/main.go", true},
+		{&Track{Src: "pkg", Include: []string{"*"}}, "pkg/sub/main.go", false},
+		{&Track{Src: "pkg", Include: []string{"**/*.go"}, Exclude: []string{"*_test.go"}}, "pkg/sub/main.go", true},
+		{&Track{Src: "pkg", Include: []string{"**/*.go"}, Exclude: []string{"*_test.go"}}, "pkg/sub/main_test.go", false},
+	}
+
+	for _, test := range tests {
+		match := test.track.Match(test.src)
+		if match != test.match {
+			t.Errorf("Track{Src: %q, Include: %q, Exclude: %q}.Match(%q) = %v, want %v",
+				test.track.Src, test.track.Include, test.track.Exclude, test.src, match, test.match)
+		}
+	}
+}
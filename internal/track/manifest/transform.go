@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Transform is a rule of the content transformation pipeline, applied in
+// order to the tracked files matching Paths. A rule has one of Replace,
+// Delete or Header.
+type Transform struct {
+	// Paths are shell patterns of the dst paths, every file when empty
+	Paths []string `yaml:"paths,omitempty"`
+	// Replace is a regular expression replaced by With, With may refer to
+	// the submatches as $1
+	Replace string `yaml:"replace,omitempty"`
+	With    string `yaml:"with,omitempty"`
+	// Delete is a regular expression of the lines deleted
+	Delete string `yaml:"delete,omitempty"`
+	// Header is inserted at the beginning of the file
+	Header string `yaml:"header,omitempty"`
+}
+
+// Match reports whether the dst path is transformed by the rule.
+func (t *Transform) Match(dst string) bool {
+	return len(t.Paths) == 0 || matchName(t.Paths, dst)
+}
+
+// Validate checks the rule has exactly one of Replace, Delete or Header and
+// that its regular expression compiles.
+func (t *Transform) Validate() error {
+	set := 0
+	for _, field := range []string{t.Replace, t.Delete, t.Header} {
+		if field != "" {
+			set++
+		}
+	}
+	if set == 0 {
+		return errors.New("transform without replace, delete or header")
+	}
+	if set > 1 {
+		return errors.New("transform with more than one of replace, delete or header")
+	}
+
+	if t.Replace != "" {
+		if _, err := regexp.Compile(t.Replace); err != nil {
+			return fmt.Errorf("invalid replace '%s': %w", t.Replace, err)
+		}
+	}
+	if t.Delete != "" {
+		if _, err := regexp.Compile(t.Delete); err != nil {
+			return fmt.Errorf("invalid delete '%s': %w", t.Delete, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+func New(src, dst *git.Repository) *Migrate {
+	return &Migrate{src, dst}
+}
+
+type Migrate struct {
+	src, dst *git.Repository
+}
+
+// Run builds the manifest from the fhub-track commit history and commits it.
+func (t *Migrate) Run() error {
+	c, err := utils.StatusEntryCount(t.dst)
+	if err != nil {
+		return err
+	}
+	if c > 0 {
+		return errors.New("the destination repository has files change")
+	}
+
+	_, err = os.Stat(filepath.Join(t.dst.Workdir(), manifest.FileName))
+	if err == nil {
+		return errors.New("the destination repository already has a manifest")
+	}
+
+	m, err := manifest.Migrate(t.src, t.dst)
+	if err != nil {
+		return err
+	}
+
+	err = m.Write(t.dst)
+	if err != nil {
+		return err
+	}
+
+	index, err := t.dst.Index()
+	if err != nil {
+		return err
+	}
+
+	err = index.AddByPath(manifest.FileName)
+	if err != nil {
+		return err
+	}
+
+	err = index.Write()
+	if err != nil {
+		return err
+	}
+
+	treeOid, err := index.WriteTree()
+	if err != nil {
+		return err
+	}
+
+	tree, err := t.dst.LookupTree(treeOid)
+	if err != nil {
+		return err
+	}
+
+	head, err := t.dst.Head()
+	if err != nil {
+		return err
+	}
+
+	commitHead, err := t.dst.LookupCommit(head.Target())
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("manifest:\n  %s", manifest.FileName)
+	_, err = utils.Commit(t.src, t.dst, nil, msg, tree, commitHead)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package mirror
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/galgotech/fhub-track/internal/log"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+var logTrack = log.New("track-mirror")
+
+// Open opens src, a local repository or the mirror of a git url. The mirror
+// is fetched when fetch is set or when it doesn't exist yet.
+func Open(src string, fetch bool) (*git.Repository, error) {
+	if !utils.IsURL(src) {
+		return git.OpenRepository(src)
+	}
+
+	if !fetch {
+		path, err := Path(src)
+		if err != nil {
+			return nil, err
+		}
+		if repo, err := git.OpenRepository(path); err == nil {
+			return repo, nil
+		}
+	}
+
+	return Fetch(src)
+}
+
+// Dir is the folder of the mirrors, $XDG_CACHE_HOME/fhub-track.
+func Dir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "fhub-track"), nil
+}
+
+// Path is the folder of the url mirror, named after the host and the path
+// of the url.
+func Path(url string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, utils.RepoName(url)+".git"), nil
+}
+
+// Fetch updates the bare mirror of url, created on the first use, and opens
+// it. All the refs of url are mirrored and HEAD follows the url default
+// branch.
+func Fetch(url string) (*git.Repository, error) {
+	path, err := Path(url)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := git.OpenRepository(path)
+	if err != nil {
+		logTrack.Info("create mirror", "url", url, "path", path)
+
+		err = os.MkdirAll(path, 0750)
+		if err != nil {
+			return nil, err
+		}
+
+		repo, err = git.InitRepository(path, true)
+		if err != nil {
+			return nil, err
+		}
+
+		// A mirror without origin can't be fetched on the next use
+		_, err = repo.Remotes.CreateWithFetchspec("origin", url, "+refs/*:refs/*")
+		if err != nil {
+			repo.Free()
+			if errRemove := os.RemoveAll(path); errRemove != nil {
+				logTrack.Error("remove mirror", "path", path, "err", errRemove.Error())
+			}
+			return nil, err
+		}
+	}
+
+	remote, err := repo.Remotes.Lookup("origin")
+	if err != nil {
+		return nil, err
+	}
+	defer remote.Free()
+
+	if remote.Url() != url {
+		return nil, fmt.Errorf("the mirror '%s' has another url '%s'", path, remote.Url())
+	}
+
+	callbacks := git.RemoteCallbacks{
+		CredentialsCallback: credentials,
+	}
+
+	err = remote.ConnectFetch(&callbacks, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	heads, err := remote.Ls()
+	remote.Disconnect()
+	if err != nil {
+		return nil, err
+	}
+
+	logTrack.Info("fetch mirror", "url", url, "path", path)
+	err = remote.Fetch(nil, &git.FetchOptions{
+		RemoteCallbacks: callbacks,
+		Prune:           git.FetchPruneOn,
+		DownloadTags:    git.DownloadTagsAll,
+	}, "fhub-track: fetch")
+	if err != nil {
+		return nil, err
+	}
+
+	if head := defaultBranch(heads); head != "" {
+		err = repo.SetHead(head)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
+
+// defaultBranch finds the branch of the remote HEAD, the remote list has the
+// HEAD commit but not its branch.
+func defaultBranch(heads []git.RemoteHead) string {
+	var head *git.Oid
+	for _, h := range heads {
+		if h.Name == "HEAD" {
+			head = h.Id
+		}
+	}
+	if head == nil {
+		return ""
+	}
+
+	branch := ""
+	for _, h := range heads {
+		if !strings.HasPrefix(h.Name, "refs/heads/") || !h.Id.Equal(head) {
+			continue
+		}
+		if h.Name == "refs/heads/main" || h.Name == "refs/heads/master" {
+			return h.Name
+		}
+		if branch == "" {
+			branch = h.Name
+		}
+	}
+	return branch
+}
+
+// credentials authenticates ssh urls with the ssh agent.
+func credentials(url string, username string, allowedTypes git.CredentialType) (*git.Credential, error) {
+	if allowedTypes&git.CredentialTypeSSHKey != 0 {
+		if username == "" {
+			username = "git"
+		}
+		return git.NewCredentialSSHKeyFromAgent(username)
+	}
+	return nil, fmt.Errorf("credentials not supported for '%s'", url)
+}
@@ -0,0 +1,53 @@
+package object
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/galgotech/fhub-track/internal/track/godeps"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+)
+
+// trackDeps tracks the files of the module packages of deps out of the
+// tracked folder, not their sub folders nor their tests. The packages keep their path
+// relative to the module, in the dst folder where the tracked package was
+// placed.
+func (t *Object) trackDeps(track *manifest.Track, deps *godeps.Deps, options Options) error {
+	root := ""
+	if track.Dst != track.Src && strings.HasSuffix(track.Dst, "/"+track.Src) {
+		root = strings.TrimSuffix(track.Dst, "/"+track.Src)
+	}
+
+	options.GoDeps = ""
+	options.FollowNewFiles = nil
+	options.Exclude = []string{godeps.TestFiles}
+	for _, pkg := range deps.Packages {
+		if manifest.IsSubPath(track.Src, pkg) {
+			continue
+		}
+
+		dst := filepath.Join(root, pkg)
+		logTrack.Info("track go dependency", "src", pkg, "dst", dst)
+
+		err := t.Run(filepath.Join(pkg, "*"), dst, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printDeps(deps *godeps.Deps) {
+	fmt.Printf("module:\n  %s\n", deps.Module)
+	if len(deps.Packages) > 0 {
+		fmt.Printf("packages:\n  %s\n", strings.Join(deps.Packages, "\n  "))
+	}
+	if len(deps.External) > 0 {
+		fmt.Printf("external:\n  %s\n", strings.Join(deps.External, "\n  "))
+	}
+	if len(deps.Issues) > 0 {
+		fmt.Printf("issues:\n  %s\n", strings.Join(deps.Issues, "\n  "))
+	}
+}
@@ -0,0 +1,156 @@
+package object
+
+import (
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/transform"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// history rewrites the src commits until commitSrc that changed the tracked
+// objects into dst commits with only the objects at their dst paths, like
+// git subtree split. The tip is merged by the track commit, nil when no
+// commit has the objects.
+func (t *Object) history(m *manifest.Manifest, track *manifest.Track, commitSrc *git.Commit) (*git.Commit, error) {
+	walk, err := t.src.Walk()
+	if err != nil {
+		return nil, err
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTopological | git.SortReverse)
+	err = walk.Push(commitSrc.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []*git.Commit{}
+	var errIterate error
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		touched, err := utils.Touches(t.src, commit, []string{track.Src})
+		if err != nil {
+			errIterate = err
+			return false
+		}
+		if touched {
+			commits = append(commits, commit)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errIterate != nil {
+		return nil, errIterate
+	}
+
+	var tip *git.Commit
+	for _, commit := range commits {
+		treeOid, err := t.historyTree(m, track, commit)
+		if err != nil {
+			return nil, err
+		}
+		// Skip commits without the objects or without changes on them
+		if treeOid == nil || (tip != nil && treeOid.Equal(tip.TreeId())) {
+			continue
+		}
+
+		tree, err := t.dst.LookupTree(treeOid)
+		if err != nil {
+			return nil, err
+		}
+
+		parents := []*git.Commit{}
+		if tip != nil {
+			parents = append(parents, tip)
+		}
+
+		oid, err := utils.ReplayCommit(t.dst, "", commit, tree, parents...)
+		if err != nil {
+			return nil, err
+		}
+
+		tip, err = t.dst.LookupCommit(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		logTrack.Debug("history commit", "src", commit.Id().String(), "dst", oid.String())
+	}
+
+	logTrack.Info("history", "commits", len(commits))
+
+	return tip, nil
+}
+
+// historyTree writes in dst the tree of the tracked objects in commit,
+// transformed by the manifest rules, nil when commit has none.
+func (t *Object) historyTree(m *manifest.Manifest, track *manifest.Track, commit *git.Commit) (*git.Oid, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	if track.Src != "." {
+		if _, err := tree.EntryByPath(track.Src); err != nil {
+			return nil, nil
+		}
+	}
+
+	objects, err := utils.TreeObjects(tree, track.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	objects = filterObjects(track, objects)
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	index, err := git.NewIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer index.Free()
+
+	for _, object := range objects {
+		entry, err := tree.EntryByPath(object)
+		if err != nil {
+			return nil, err
+		}
+
+		oid, err := t.historyBlob(m, track.DstPath(object), entry)
+		if err != nil {
+			return nil, err
+		}
+
+		err = index.Add(&git.IndexEntry{
+			Path: track.DstPath(object),
+			Mode: entry.Filemode,
+			Id:   oid,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return index.WriteTreeTo(t.dst)
+}
+
+func (t *Object) historyBlob(m *manifest.Manifest, path string, entry *git.TreeEntry) (*git.Oid, error) {
+	if entry.Filemode == git.FilemodeLink {
+		return entry.Id, utils.CopyBlob(t.src, t.dst, entry.Id)
+	}
+
+	blob, err := t.src.LookupBlob(entry.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := transform.Apply(m, path, blob.Contents())
+	if err != nil {
+		return nil, err
+	}
+
+	return t.dst.CreateBlobFromBuffer(contents)
+}
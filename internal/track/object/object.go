@@ -3,12 +3,13 @@ package object
 import (
 	"errors"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/galgotech/fhub-track/internal/log"
+	"github.com/galgotech/fhub-track/internal/track/godeps"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/transform"
 	"github.com/galgotech/fhub-track/internal/track/utils"
 	git "github.com/libgit2/git2go/v34"
 )
@@ -23,8 +24,49 @@ func New(src, dst *git.Repository) *Object {
 
 var logTrack = log.New("track-object")
 
-func (t *Object) Run(srcObject, dstObject string) error {
-	logTrack.Info("start track object", "srcObject", srcObject, "dstObject", dstObject)
+// GoDepsMode is what the object command does with the Go dependency closure
+// of the tracked package.
+type GoDepsMode string
+
+const (
+	// GoDepsList prints the closure without tracking
+	GoDepsList GoDepsMode = "list"
+	// GoDepsTrack tracks the module packages of the closure
+	GoDepsTrack GoDepsMode = "track"
+)
+
+type Options struct {
+	// Rev is the src revision (tag, branch or commit), HEAD by default
+	Rev string
+	// Exclude are the patterns of the files skipped
+	Exclude []string
+	// AllowDirty tracks from HEAD even with uncommitted changes in src
+	AllowDirty bool
+	// WithHistory merges the src history of the objects rewritten to dst
+	// paths
+	WithHistory bool
+	// Imports are Go import rewrite rules, "<from> => <to>", added to the
+	// manifest and applied to the copied files
+	Imports []string
+	// Source is the name of the src repository in the manifest
+	Source string
+	// FollowNewFiles sets whether update tracks the files added under the
+	// rule, the rule default when nil
+	FollowNewFiles *bool
+	// GoDeps lists or tracks the Go packages of the src module imported by
+	// the tracked package
+	GoDeps GoDepsMode
+}
+
+// Run tracks srcObject in dstObject. The objects are read from the tree of
+// the src revision, HEAD by default, so only committed content is copied.
+// srcObject may be a shell pattern.
+func (t *Object) Run(srcObject, dstObject string, options Options) error {
+	logTrack.Info("start track object", "srcObject", srcObject, "dstObject", dstObject, "rev", options.Rev, "exclude", options.Exclude)
+
+	if options.GoDeps != "" && options.GoDeps != GoDepsList && options.GoDeps != GoDepsTrack {
+		return fmt.Errorf("invalid go deps mode '%s'", options.GoDeps)
+	}
 
 	c, err := utils.StatusEntryCount(t.dst)
 	if err != nil {
@@ -34,28 +76,131 @@ func (t *Object) Run(srcObject, dstObject string) error {
 		return errors.New("the destination repository has files change")
 	}
 
-	allSrcObjects, err := t.searchObjectsInWorkTree(srcObject)
+	m, err := manifest.Load(t.src, t.dst)
+	if err != nil {
+		return err
+	}
+
+	track := newTrack(srcObject, dstObject, options.Exclude)
+	track.Source = options.Source
+	track.FollowNewFiles = options.FollowNewFiles
+
+	for _, rule := range options.Imports {
+		i, err := manifest.ParseImport(rule)
+		if err != nil {
+			return err
+		}
+		m.AddImport(i)
+	}
+
+	rev := options.Rev
+	if rev == "" {
+		rev = "HEAD"
+
+		// Uncommitted changes are not copied, refuse instead of silently
+		// tracking content different from the work tree
+		if !options.AllowDirty && !t.src.IsBare() {
+			modified, err := utils.Modified(t.src, track.Src)
+			if err != nil {
+				return err
+			}
+			if len(modified) > 0 {
+				return fmt.Errorf("the source repository has uncommitted changes (%s), commit them or use --allow-dirty", strings.Join(modified, ", "))
+			}
+		}
+	}
+
+	commitSrc, err := utils.RevCommit(t.src, rev)
+	if err != nil {
+		return err
+	}
+
+	repo, err := utils.RepoUrl(t.src)
+	if err != nil {
+		return err
+	}
+
+	treeSrc, err := commitSrc.Tree()
+	if err != nil {
+		return err
+	}
+
+	var deps *godeps.Deps
+	if options.GoDeps != "" {
+		deps, err = godeps.Closure(t.src, treeSrc, track.Src, nil)
+		if err != nil {
+			return err
+		}
+		printDeps(deps)
+
+		if options.GoDeps == GoDepsList {
+			return nil
+		}
+	}
+
+	allSrcObjects, err := utils.TreeObjects(treeSrc, track.Src)
 	if err != nil {
 		return err
 	}
 
-	allDstObjects := renameObjectsToDst(allSrcObjects, srcObject, dstObject)
+	allSrcObjects = filterObjects(track, allSrcObjects)
+	if len(allSrcObjects) == 0 {
+		return fmt.Errorf("no objects match '%s'", srcObject)
+	}
+
+	allDstObjects := renameObjectsToDst(allSrcObjects, track)
 
-	err = t.copyObject(allSrcObjects, allDstObjects)
+	err = t.copyObject(m, treeSrc, allSrcObjects, allDstObjects)
 	if err != nil {
 		return err
 	}
 
+	var historyTip *git.Commit
+	if options.WithHistory {
+		historyTip, err = t.history(m, track, commitSrc)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.AddTrack(track)
+
 	index, err := t.dst.Index()
 	if err != nil {
 		return err
 	}
 
-	for _, object := range allDstObjects {
+	for i, object := range allDstObjects {
 		err := index.AddByPath(object)
 		if err != nil {
 			return err
 		}
+
+		// The baseline is the src blob, the dst one differs when transformed
+		entry, err := treeSrc.EntryByPath(allSrcObjects[i])
+		if err != nil {
+			return err
+		}
+
+		m.Add(&manifest.Object{
+			Src:    allSrcObjects[i],
+			Dst:    object,
+			Repo:   repo,
+			Commit: commitSrc.Id().String(),
+			Blob:   entry.Id.String(),
+			Mode:   manifest.FormatMode(entry.Filemode),
+			Source: options.Source,
+		})
+	}
+
+	err = m.Write(t.dst)
+	if err != nil {
+		return err
+	}
+
+	err = index.AddByPath(manifest.FileName)
+	if err != nil {
+		return err
 	}
 
 	err = index.Write()
@@ -77,7 +222,7 @@ func (t *Object) Run(srcObject, dstObject string) error {
 	if err != nil {
 		return err
 	}
-	if c > 0 {
+	if c > 0 || historyTip != nil {
 		zipObjects, err := zipObjects(allSrcObjects, allDstObjects)
 		if err != nil {
 			return err
@@ -92,90 +237,58 @@ func (t *Object) Run(srcObject, dstObject string) error {
 			}
 			parents = append(parents, commit)
 		}
+		if historyTip != nil {
+			parents = append(parents, historyTip)
+		}
 
 		msg := fmt.Sprintf("files:\n  %s", strings.Join(zipObjects, "\n  "))
-		_, err = utils.Commit(t.dst, msg, tree, parents...)
+		_, err = utils.Commit(t.src, t.dst, commitSrc.Id(), msg, tree, parents...)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
-}
-
-func (t *Object) searchObjectsInWorkTree(object string) ([]string, error) {
-	allObjects := []string{}
-
-	objectPath := filepath.Join(t.src.Workdir(), object)
-	file, err := os.Open(objectPath) // For read access.
-	if err != nil {
-		return nil, err
+	if deps != nil {
+		return t.trackDeps(track, deps, options)
 	}
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return nil, err
-	}
-
-	if !fileInfo.IsDir() {
-		return []string{object}, nil
-	}
-
-	subObjectsInfo, err := os.ReadDir(objectPath)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, subObjectInfo := range subObjectsInfo {
-		object := filepath.Join(object, subObjectInfo.Name())
-		// Walk folders recursively
-		if subObjectInfo.IsDir() {
-			newObjects, err := t.searchObjectsInWorkTree(object)
-			if err != nil {
-				return nil, err
-			}
-
-			allObjects = append(allObjects, newObjects...)
-		} else {
-			allObjects = append(allObjects, object)
-		}
-	}
-
-	return allObjects, nil
+	return nil
 }
 
-func (t *Object) copyObject(allSrcObjects, allDstObjects []string) error {
+// copyObject writes the blobs of tree to dst through the object database,
+// the src work tree is not used. File modes and symbolic links are kept, the
+// contents are transformed by the manifest rules.
+func (t *Object) copyObject(m *manifest.Manifest, tree *git.Tree, allSrcObjects, allDstObjects []string) error {
 	if len(allSrcObjects) != len(allDstObjects) {
 		return errors.New("allSrcObjects and allDstObjects have different length")
 	}
 
-	srcWorkdir := t.src.Workdir()
 	dstWorkdir := t.dst.Workdir()
 
 	for i := 0; i < len(allSrcObjects); i++ {
-		srcObject := filepath.Join(srcWorkdir, allSrcObjects[i])
 		dstObject := filepath.Join(dstWorkdir, allDstObjects[i])
 
-		logTrack.Debug("copy object", "src", srcObject, "dst", dstObject)
+		logTrack.Debug("copy object", "src", allSrcObjects[i], "dst", dstObject)
 
-		fileRead, err := os.OpenFile(srcObject, os.O_RDONLY, os.ModeAppend)
+		entry, err := tree.EntryByPath(allSrcObjects[i])
 		if err != nil {
 			return err
 		}
-		defer fileRead.Close()
 
-		err = os.MkdirAll(filepath.Dir(dstObject), 0750)
+		blob, err := t.src.LookupBlob(entry.Id)
 		if err != nil {
 			return err
 		}
 
-		fileWrite, err := os.OpenFile(dstObject, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-		if err != nil {
-			return err
+		contents := blob.Contents()
+		if entry.Filemode != git.FilemodeLink {
+			contents, err = transform.Apply(m, allDstObjects[i], contents)
+			if err != nil {
+				return err
+			}
 		}
-		defer fileWrite.Close()
 
-		_, err = io.Copy(fileWrite, fileRead)
+		err = utils.WriteObject(dstObject, contents, entry.Filemode)
 		if err != nil {
 			return err
 		}
@@ -184,12 +297,40 @@ func (t *Object) copyObject(allSrcObjects, allDstObjects []string) error {
 	return nil
 }
 
-func renameObjectsToDst(objects []string, srcObject, dstObject string) []string {
+// newTrack builds the rule of the object arguments. A src with shell
+// patterns tracks the matching files of the folder before the first pattern.
+func newTrack(srcObject, dstObject string, exclude []string) *manifest.Track {
+	base, pattern := utils.SplitGlob(srcObject)
+	track := &manifest.Track{
+		Src:     base,
+		Dst:     dstObject,
+		Exclude: exclude,
+	}
+
+	if pattern != "" {
+		track.Include = []string{pattern}
+		if dstObject == srcObject {
+			track.Dst = base
+		}
+	}
+
+	return track
+}
+
+func filterObjects(track *manifest.Track, objects []string) []string {
+	filtered := []string{}
+	for _, object := range objects {
+		if track.Match(object) {
+			filtered = append(filtered, object)
+		}
+	}
+	return filtered
+}
+
+func renameObjectsToDst(objects []string, track *manifest.Track) []string {
 	dstObjects := make([]string, len(objects))
-	srcObject = filepath.Clean(srcObject)
-	dstObject = filepath.Clean(dstObject)
 	for i, object := range objects {
-		dstObjects[i] = filepath.Clean(strings.Replace(object, srcObject, dstObject, 1))
+		dstObjects[i] = track.DstPath(object)
 	}
 	return dstObjects
 }
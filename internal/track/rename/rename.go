@@ -4,7 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/galgotech/fhub-track/internal/log"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
 	"github.com/galgotech/fhub-track/internal/track/utils"
 	git "github.com/libgit2/git2go/v34"
 )
@@ -13,10 +17,14 @@ func New(src, dst *git.Repository) *Rename {
 	return &Rename{src, dst}
 }
 
+var logTrack = log.New("track-rename")
+
 type Rename struct {
 	src, dst *git.Repository
 }
 
+// Run renames a dst object, a file or a folder, and the tracked objects in
+// it. Paths are relative to the dst work tree.
 func (t *Rename) Run(oldObject string, newObject string) error {
 	c, err := utils.StatusEntryCount(t.dst)
 	if err != nil {
@@ -27,7 +35,32 @@ func (t *Rename) Run(oldObject string, newObject string) error {
 		return errors.New("unable to track files because they were in stage")
 	}
 
-	err = os.Rename(oldObject, newObject)
+	m, err := manifest.Load(t.src, t.dst)
+	if err != nil {
+		return err
+	}
+
+	oldObject, err = t.relPath(oldObject)
+	if err != nil {
+		return err
+	}
+	newObject, err = t.relPath(newObject)
+	if err != nil {
+		return err
+	}
+
+	oldPath := filepath.Join(t.dst.Workdir(), oldObject)
+	newPath := filepath.Join(t.dst.Workdir(), newObject)
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("object '%s' already exists", newObject)
+	}
+
+	err = os.MkdirAll(filepath.Dir(newPath), 0750)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(oldPath, newPath)
 	if err != nil {
 		return err
 	}
@@ -37,12 +70,40 @@ func (t *Rename) Run(oldObject string, newObject string) error {
 		return err
 	}
 
-	err = index.RemoveByPath(oldObject)
+	oldObjects := []string{}
+	for i := uint(0); i < index.EntryCount(); i++ {
+		entry, err := index.EntryByIndex(i)
+		if err != nil {
+			return err
+		}
+		if manifest.IsSubPath(oldObject, entry.Path) {
+			oldObjects = append(oldObjects, entry.Path)
+		}
+	}
+
+	for _, path := range oldObjects {
+		newPath := filepath.Join(newObject, strings.TrimPrefix(path, oldObject))
+		logTrack.Debug("rename object", "old", path, "new", newPath)
+
+		err = index.RemoveByPath(path)
+		if err != nil {
+			return err
+		}
+
+		err = index.AddByPath(newPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.Rename(oldObject, newObject)
+
+	err = m.Write(t.dst)
 	if err != nil {
 		return err
 	}
 
-	err = index.AddByPath(newObject)
+	err = index.AddByPath(manifest.FileName)
 	if err != nil {
 		return err
 	}
@@ -73,10 +134,29 @@ func (t *Rename) Run(oldObject string, newObject string) error {
 	}
 
 	msg := fmt.Sprintf("rename:\n  %s -> %s", oldObject, newObject)
-	_, err = utils.Commit(t.dst, msg, tree, commitHead)
+	_, err = utils.Commit(t.src, t.dst, nil, msg, tree, commitHead)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// relPath cleans a path relative to the dst work tree. Absolute paths are
+// accepted inside the work tree.
+func (t *Rename) relPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(t.dst.Workdir(), path)
+		if err != nil {
+			return "", err
+		}
+		path = rel
+	}
+
+	path = filepath.Clean(path)
+	if path == "." || path == ".." || strings.HasPrefix(path, "../") {
+		return "", fmt.Errorf("object '%s' is out of the destination repository", path)
+	}
+
+	return path, nil
+}
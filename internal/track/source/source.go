@@ -0,0 +1,118 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/mirror"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+func New(dst *git.Repository) *Source {
+	return &Source{dst}
+}
+
+type Source struct {
+	dst *git.Repository
+}
+
+// Add records the src repository at path, or url, with name in the manifest
+// and commits it. The path is kept relative to the dst work tree.
+func (t *Source) Add(name, path string) error {
+	c, err := utils.StatusEntryCount(t.dst)
+	if err != nil {
+		return err
+	}
+	if c > 0 {
+		return errors.New("the destination repository has files change")
+	}
+
+	m, err := manifest.Read(t.dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("the destination repository has no manifest, track an object or run migrate first")
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := mirror.Open(path, false)
+	if err != nil {
+		return fmt.Errorf("source '%s' is not a git repository: %w", path, err)
+	}
+
+	// Urls are kept as they are
+	rel := path
+	if !utils.IsURL(path) {
+		rel, err = filepath.Rel(filepath.Clean(t.dst.Workdir()), path)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.AddSource(&manifest.Source{Name: name, Path: rel})
+
+	err = m.Write(t.dst)
+	if err != nil {
+		return err
+	}
+
+	index, err := t.dst.Index()
+	if err != nil {
+		return err
+	}
+
+	err = index.AddByPath(manifest.FileName)
+	if err != nil {
+		return err
+	}
+
+	err = index.Write()
+	if err != nil {
+		return err
+	}
+
+	treeOid, err := index.WriteTree()
+	if err != nil {
+		return err
+	}
+
+	tree, err := t.dst.LookupTree(treeOid)
+	if err != nil {
+		return err
+	}
+
+	commitHead, err := utils.HeadCommit(t.dst)
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("source:\n  %s:%s", name, rel)
+	_, err = utils.Commit(src, t.dst, nil, msg, tree, commitHead)
+	return err
+}
+
+// List prints the sources of the manifest with their tracked objects count.
+func (t *Source) List() error {
+	m, err := manifest.Read(t.dst)
+	if err != nil {
+		return err
+	}
+
+	count := map[string]int{}
+	for _, object := range m.Objects {
+		count[object.Source]++
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPATH\tOBJECTS")
+	for _, source := range m.Sources {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", source.Name, source.Path, count[source.Name])
+	}
+
+	return w.Flush()
+}
@@ -2,26 +2,45 @@ package status
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
 
-	"github.com/galgotech/fhub-track/internal/track/utils"
+	"github.com/galgotech/fhub-track/internal/setting"
+	"github.com/galgotech/fhub-track/internal/track/update"
 	git "github.com/libgit2/git2go/v34"
 )
 
-func New(src, dst *git.Repository) *Status {
-	return &Status{src, dst}
+func New(setting *setting.Setting, src, dst *git.Repository) *Status {
+	return &Status{setting, src, dst}
 }
 
 type Status struct {
+	setting  *setting.Setting
 	src, dst *git.Repository
 }
 
 func (t *Status) Run() error {
-	status, err := utils.Status(t.dst)
+	status, err := update.New(t.setting, t.src, t.dst, update.Options{}).Status()
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(status)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tDST\tSRC\tBASELINE")
+	for _, object := range status {
+		state := string(object.State)
+		if object.Uncommitted {
+			state += " (uncommitted)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", state, object.Dst, object.Src, shortCommit(object.Commit))
+	}
+
+	return w.Flush()
+}
 
-	return nil
+func shortCommit(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
 }
@@ -1,16 +1,25 @@
 package track
 
 import (
+	"errors"
+	"fmt"
 	"path/filepath"
 
 	git "github.com/libgit2/git2go/v34"
 
 	"github.com/galgotech/fhub-track/internal/log"
 	"github.com/galgotech/fhub-track/internal/setting"
+	"github.com/galgotech/fhub-track/internal/track/extract"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/migrate"
+	"github.com/galgotech/fhub-track/internal/track/mirror"
 	"github.com/galgotech/fhub-track/internal/track/object"
 	"github.com/galgotech/fhub-track/internal/track/rename"
+	"github.com/galgotech/fhub-track/internal/track/source"
 	"github.com/galgotech/fhub-track/internal/track/status"
+	"github.com/galgotech/fhub-track/internal/track/untrack"
 	"github.com/galgotech/fhub-track/internal/track/update"
+	"github.com/galgotech/fhub-track/internal/track/utils"
 )
 
 var logTrack = log.New("track")
@@ -20,14 +29,16 @@ type Track struct {
 	dst *git.Repository
 }
 
-func Object(setting *setting.Setting, srcObject, dstObject string) error {
-	src, dst, err := initRepos(setting)
+func Object(setting *setting.Setting, srcObject, dstObject string, options object.Options) error {
+	src, dst, err := initRepos(setting, true)
 	if err != nil {
 		return err
 	}
 
+	options.Source = setting.Source
+
 	o := object.New(src, dst)
-	err = o.Run(srcObject, dstObject)
+	err = o.Run(srcObject, dstObject, options)
 	if err != nil {
 		logTrack.Error("Track object fail", "object", srcObject, "error", err.Error())
 		return err
@@ -36,8 +47,54 @@ func Object(setting *setting.Setting, srcObject, dstObject string) error {
 	return nil
 }
 
+func ExtractPackage(setting *setting.Setting, srcPkg, dstPkg string, options object.Options) error {
+	src, dst, err := initRepos(setting, true)
+	if err != nil {
+		return err
+	}
+
+	e := extract.New(src, dst)
+	options.Source = setting.Source
+	err = e.Run(srcPkg, dstPkg, options)
+	if err != nil {
+		logTrack.Error("Extract package fail", "package", srcPkg, "error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func SourceAdd(setting *setting.Setting, name, path string) error {
+	dst, err := initDst(setting)
+	if err != nil {
+		return err
+	}
+
+	if !utils.IsURL(path) && !filepath.IsAbs(path) {
+		path = filepath.Join(setting.RootPath, path)
+	}
+
+	s := source.New(dst)
+	err = s.Add(name, path)
+	if err != nil {
+		logTrack.Error("Add source fail", "name", name, "path", path, "error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func SourceList(setting *setting.Setting) error {
+	dst, err := initDst(setting)
+	if err != nil {
+		return err
+	}
+
+	return source.New(dst).List()
+}
+
 func Rename(setting *setting.Setting, old string, new string) error {
-	src, dst, err := initRepos(setting)
+	src, dst, err := initRepos(setting, false)
 	if err != nil {
 		return err
 	}
@@ -52,13 +109,29 @@ func Rename(setting *setting.Setting, old string, new string) error {
 	return nil
 }
 
-func Update(setting *setting.Setting) error {
-	src, dst, err := initRepos(setting)
+func Untrack(setting *setting.Setting, dstObject string, remove bool) error {
+	src, dst, err := initRepos(setting, false)
 	if err != nil {
 		return err
 	}
 
-	u := update.New(setting, src, dst)
+	u := untrack.New(src, dst)
+	err = u.Run(dstObject, remove)
+	if err != nil {
+		logTrack.Error("Untrack object fail", "object", dstObject, "error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func Update(setting *setting.Setting, options update.Options) error {
+	src, dst, err := initRepos(setting, true)
+	if err != nil {
+		return err
+	}
+
+	u := update.New(setting, src, dst, options)
 
 	err = u.Run()
 	if err != nil {
@@ -69,13 +142,63 @@ func Update(setting *setting.Setting) error {
 	return nil
 }
 
+func UpdateContinue(setting *setting.Setting, options update.Options) error {
+	src, dst, err := initRepos(setting, false)
+	if err != nil {
+		return err
+	}
+
+	u := update.New(setting, src, dst, options)
+
+	err = u.Continue()
+	if err != nil {
+		logTrack.Error("Update continue fail", "error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func UpdateAbort(setting *setting.Setting) error {
+	src, dst, err := initRepos(setting, false)
+	if err != nil {
+		return err
+	}
+
+	u := update.New(setting, src, dst, update.Options{})
+
+	err = u.Abort()
+	if err != nil {
+		logTrack.Error("Update abort fail", "error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func Migrate(setting *setting.Setting) error {
+	src, dst, err := initRepos(setting, false)
+	if err != nil {
+		return err
+	}
+
+	m := migrate.New(src, dst)
+	err = m.Run()
+	if err != nil {
+		logTrack.Error("Migrate fail", "error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
 func Status(setting *setting.Setting) error {
-	src, dst, err := initRepos(setting)
+	src, dst, err := initRepos(setting, false)
 	if err != nil {
 		return err
 	}
 
-	s := status.New(src, dst)
+	s := status.New(setting, src, dst)
 	err = s.Run()
 	if err != nil {
 		logTrack.Error("Status fail", "error", err.Error())
@@ -85,20 +208,56 @@ func Status(setting *setting.Setting) error {
 	return nil
 }
 
-func initRepos(setting *setting.Setting) (*git.Repository, *git.Repository, error) {
-	// Source repository
-	src, err := git.OpenRepository(filepath.Join(setting.RootPath, setting.SrcRepo))
+// initRepos opens the dst and src repositories. A src url is fetched in its
+// mirror when fetch is set, the commands reading only the baselines use the
+// mirror as is.
+func initRepos(setting *setting.Setting, fetch bool) (*git.Repository, *git.Repository, error) {
+	dst, err := initDst(setting)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srcPath := setting.SrcRepo
+	if !utils.IsURL(srcPath) && !filepath.IsAbs(srcPath) {
+		srcPath = filepath.Join(setting.RootPath, srcPath)
+	}
+	if setting.Source != "" {
+		m, err := manifest.Read(dst)
+		if err != nil {
+			logTrack.Error("Fail read manifest", "err", err.Error(), "source", setting.Source)
+			return nil, nil, err
+		}
+
+		source := m.FindSource(setting.Source)
+		if source == nil {
+			return nil, nil, fmt.Errorf("source '%s' not found, add it with source add", setting.Source)
+		}
+
+		srcPath = source.Path
+		if !utils.IsURL(srcPath) && !filepath.IsAbs(srcPath) {
+			srcPath = filepath.Join(dst.Workdir(), srcPath)
+		}
+	} else if setting.SrcRepo == "" {
+		return nil, nil, errors.New("no source repository, use --src or --source")
+	}
+
+	// Source repository, urls are fetched in a mirror
+	src, err := mirror.Open(srcPath, fetch)
 	if err != nil {
-		logTrack.Error("Fail start src repository", "err", err.Error(), "repositoryPath", setting.SrcRepo)
+		logTrack.Error("Fail start src repository", "err", err.Error(), "repositoryPath", srcPath)
 		return nil, nil, err
 	}
 
+	return src, dst, nil
+}
+
+func initDst(setting *setting.Setting) (*git.Repository, error) {
 	// Destionation repository
 	dst, err := git.OpenRepository(filepath.Join(setting.RootPath, setting.DstRepo))
 	if err != nil {
 		logTrack.Error("Fail start dst repository", "err", err, "WorkTree", setting.DstRepo)
-		return nil, nil, err
+		return nil, err
 	}
 
-	return src, dst, nil
+	return dst, nil
 }
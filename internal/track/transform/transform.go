@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/galgotech/fhub-track/internal/log"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+)
+
+var logTrack = log.New("track-transform")
+
+// Apply transforms the contents of the dst path with the rules of the
+// manifest, the Go imports first and then the pipeline in order. The
+// contents are returned as they are when no rule applies.
+func Apply(m *manifest.Manifest, path string, contents []byte) ([]byte, error) {
+	var err error
+	if len(m.Imports) > 0 && filepath.Ext(path) == ".go" {
+		contents, err = Imports(path, contents, m.Imports)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, rule := range m.Transforms {
+		if !rule.Match(path) {
+			continue
+		}
+
+		contents, err = Pipeline(rule, contents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return contents, nil
+}
+
+// Pipeline applies a transformation rule to the contents. A rule with more
+// than one transformation is refused.
+func Pipeline(rule *manifest.Transform, contents []byte) ([]byte, error) {
+	err := rule.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case rule.Replace != "":
+		re := regexp.MustCompile(rule.Replace)
+		return re.ReplaceAll(contents, []byte(rule.With)), nil
+
+	case rule.Delete != "":
+		re := regexp.MustCompile(rule.Delete)
+		return deleteLines(re, contents), nil
+
+	default:
+		header := []byte(rule.Header)
+		if !bytes.HasSuffix(header, []byte("\n")) {
+			header = append(header, '\n')
+		}
+		// The header is inserted once, the rule runs on every update
+		if bytes.HasPrefix(contents, header) {
+			return contents, nil
+		}
+		return append(header, contents...), nil
+	}
+}
+
+func deleteLines(re *regexp.Regexp, contents []byte) []byte {
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+	kept := make([][]byte, 0, len(lines))
+	for _, line := range lines {
+		if !re.Match(bytes.TrimRight(line, "\r\n")) {
+			kept = append(kept, line)
+		}
+	}
+	return bytes.Join(kept, nil)
+}
+
+// Imports rewrites the import paths of a Go file. A file that can't be parsed
+// is kept as it is.
+func Imports(path string, contents []byte, rules []*manifest.Import) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, contents, parser.ParseComments)
+	if err != nil {
+		logTrack.Warn("go file not parsed, imports not rewritten", "path", path, "error", err.Error())
+		return contents, nil
+	}
+
+	rewritten := false
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			if newPath, ok := rule.Rewrite(importPath); ok {
+				spec.Path.Value = strconv.Quote(newPath)
+				rewritten = true
+				break
+			}
+		}
+	}
+
+	if !rewritten {
+		return contents, nil
+	}
+
+	var buf bytes.Buffer
+	err = format.Node(&buf, fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
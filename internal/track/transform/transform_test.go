@@ -0,0 +1,165 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+)
+
+func TestImports(t *testing.T) {
+	rules := []*manifest.Import{
+		{From: "github.com/src/module/internal/diff", To: "github.com/dst/module/internal/diff"},
+		{From: "github.com/src/module", To: "github.com/dst/module/third_party/module"},
+	}
+
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{
+			"exact path",
+			"package a\n\nimport \"github.com/src/module/internal/diff\"\n",
+			"package a\n\nimport \"github.com/dst/module/internal/diff\"\n",
+		},
+		{
+			"sub package",
+			"package a\n\nimport \"github.com/src/module/internal/diff/lcs\"\n",
+			"package a\n\nimport \"github.com/dst/module/internal/diff/lcs\"\n",
+		},
+		{
+			"first matching rule",
+			"package a\n\nimport \"github.com/src/module/internal/event\"\n",
+			"package a\n\nimport \"github.com/dst/module/third_party/module/internal/event\"\n",
+		},
+		{
+			"named and grouped imports",
+			"package a\n\nimport (\n\t\"fmt\"\n\n\td \"github.com/src/module/internal/diff\"\n)\n\nvar _ = fmt.Sprint(d.Unified)\n",
+			"package a\n\nimport (\n\t\"fmt\"\n\n\td \"github.com/dst/module/internal/diff\"\n)\n\nvar _ = fmt.Sprint(d.Unified)\n",
+		},
+		{
+			"prefix out of a path boundary",
+			"package a\n\nimport \"github.com/src/modules\"\n",
+			"package a\n\nimport \"github.com/src/modules\"\n",
+		},
+		{
+			"unformatted file without rewrite",
+			"package a\nimport \"fmt\"\nvar _ = fmt.Sprint( 1 )\n",
+			"package a\nimport \"fmt\"\nvar _ = fmt.Sprint( 1 )\n",
+		},
+		{
+			"file not parsed",
+			"package a\n\nimport \"github.com/src/module\n",
+			"package a\n\nimport \"github.com/src/module\n",
+		},
+	}
+
+	for _, test := range tests {
+		contents, err := Imports("a.go", []byte(test.contents), rules)
+		if err != nil {
+			t.Errorf("%s: Imports() error %v", test.name, err)
+			continue
+		}
+		if string(contents) != test.want {
+			t.Errorf("%s: Imports() = %q, want %q", test.name, contents, test.want)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     *manifest.Transform
+		contents string
+		want     string
+		err      bool
+	}{
+		{
+			"replace with submatch",
+			&manifest.Transform{Replace: `Copyright (\d+) Src`, With: "Copyright $1 Dst"},
+			"// Copyright 2023 Src\npackage a\n",
+			"// Copyright 2023 Dst\npackage a\n",
+			false,
+		},
+		{
+			"replace every match",
+			&manifest.Transform{Replace: `src`, With: "dst"},
+			"src src\nsrc\n",
+			"dst dst\ndst\n",
+			false,
+		},
+		{
+			"delete lines",
+			&manifest.Transform{Delete: `^//go:build`},
+			"//go:build linux\n\npackage a\n//go:build windows\n",
+			"\npackage a\n",
+			false,
+		},
+		{
+			"delete crlf lines",
+			&manifest.Transform{Delete: `^drop$`},
+			"keep\r\ndrop\r\nkeep\r\n",
+			"keep\r\nkeep\r\n",
+			false,
+		},
+		{
+			"delete last line without newline",
+			&manifest.Transform{Delete: `^drop$`},
+			"keep\ndrop",
+			"keep\n",
+			false,
+		},
+		{
+			"header",
+			&manifest.Transform{Header: "// Code tracked from src."},
+			"package a\n",
+			"// Code tracked from src.\npackage a\n",
+			false,
+		},
+		{
+			"header inserted once",
+			&manifest.Transform{Header: "// Code tracked from src.\n"},
+			"// Code tracked from src.\npackage a\n",
+			"// Code tracked from src.\npackage a\n",
+			false,
+		},
+		{
+			"several transformations",
+			&manifest.Transform{Replace: `a`, With: "b", Header: "// header"},
+			"package a\n",
+			"",
+			true,
+		},
+		{
+			"no transformation",
+			&manifest.Transform{Paths: []string{"*.go"}},
+			"package a\n",
+			"",
+			true,
+		},
+		{
+			"invalid expression",
+			&manifest.Transform{Delete: `(`},
+			"package a\n",
+			"",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		contents, err := Pipeline(test.rule, []byte(test.contents))
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: Pipeline() = %q, want an error", test.name, contents)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Pipeline() error %v", test.name, err)
+			continue
+		}
+		if string(contents) != test.want {
+			t.Errorf("%s: Pipeline() = %q, want %q", test.name, contents, test.want)
+		}
+	}
+}
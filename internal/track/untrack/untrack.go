@@ -0,0 +1,111 @@
+package untrack
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/galgotech/fhub-track/internal/log"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+func New(src, dst *git.Repository) *Untrack {
+	return &Untrack{src, dst}
+}
+
+var logTrack = log.New("track-untrack")
+
+type Untrack struct {
+	src, dst *git.Repository
+}
+
+// Run stops tracking the objects in the dst path, a file or a folder. The
+// dst files are kept unless remove is set.
+func (t *Untrack) Run(dstObject string, remove bool) error {
+	c, err := utils.StatusEntryCount(t.dst)
+	if err != nil {
+		return err
+	}
+	if c > 0 {
+		return errors.New("the destination repository has files change")
+	}
+
+	m, err := manifest.Load(t.src, t.dst)
+	if err != nil {
+		return err
+	}
+
+	dstObject = filepath.Clean(dstObject)
+	objects := m.Untrack(dstObject)
+	if len(objects) == 0 {
+		return fmt.Errorf("object '%s' is not tracked", dstObject)
+	}
+
+	index, err := t.dst.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		logTrack.Info("untrack object", "dst", object.Dst, "src", object.Src, "remove", remove)
+		if !remove {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(t.dst.Workdir(), object.Dst))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		err = index.RemoveByPath(object.Dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.Write(t.dst)
+	if err != nil {
+		return err
+	}
+
+	err = index.AddByPath(manifest.FileName)
+	if err != nil {
+		return err
+	}
+
+	err = index.Write()
+	if err != nil {
+		return err
+	}
+
+	treeOid, err := index.WriteTree()
+	if err != nil {
+		return err
+	}
+
+	tree, err := t.dst.LookupTree(treeOid)
+	if err != nil {
+		return err
+	}
+
+	head, err := t.dst.Head()
+	if err != nil {
+		return err
+	}
+
+	commitHead, err := t.dst.LookupCommit(head.Target())
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("untrack:\n  %s", dstObject)
+	_, err = utils.Commit(t.src, t.dst, nil, msg, tree, commitHead)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package update
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// newObjects lists the src files, added since the objects were tracked, that
+// match a tracking rule following new files.
+func (t *Update) newObjects(objects listPathObject) ([]*manifest.Object, error) {
+	tree, err := t.headSrc.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := utils.RepoUrl(t.src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tracked src paths, at the baseline and at head
+	tracked := map[string]bool{}
+	for _, objectDst := range objects {
+		objectSrc := objectDst.link
+		tracked[objectSrc.path] = true
+		if objectSrc.head != nil {
+			tracked[objectSrc.head.path] = true
+		}
+	}
+
+	newObjects := []*manifest.Object{}
+	for _, track := range t.manifest.Tracks {
+		if !track.FollowsNewFiles() || track.Source != t.setting.Source {
+			continue
+		}
+
+		srcObjects, err := utils.TreeObjects(tree, track.Src)
+		if err != nil {
+			logTrack.Warn("tracked object not found", "src", track.Src)
+			continue
+		}
+
+		for _, src := range srcObjects {
+			dst := track.DstPath(src)
+			if tracked[src] || !track.Match(src) || t.manifest.Find(dst) != nil || t.manifest.IsUntracked(dst) {
+				continue
+			}
+
+			entry, err := tree.EntryByPath(src)
+			if err != nil {
+				return nil, err
+			}
+
+			tracked[src] = true
+			newObjects = append(newObjects, &manifest.Object{
+				Src:    src,
+				Dst:    dst,
+				Repo:   repo,
+				Commit: t.headSrc.Id().String(),
+				Blob:   entry.Id.String(),
+				Mode:   manifest.FormatMode(entry.Filemode),
+				Source: track.Source,
+			})
+		}
+	}
+
+	return newObjects, nil
+}
+
+func (t *Update) addObject(object *manifest.Object) error {
+	path := filepath.Join(t.dst.Workdir(), object.Dst)
+	if _, err := os.Lstat(path); err == nil {
+		logTrack.Warn("added object already exists in dst", "path", object.Dst)
+		return nil
+	}
+
+	oid, err := git.NewOid(object.Blob)
+	if err != nil {
+		return err
+	}
+
+	blob, err := t.src.LookupBlob(oid)
+	if err != nil {
+		return err
+	}
+
+	contents, err := t.transform(object.Dst, uint16(object.FileMode()), blob.Contents())
+	if err != nil {
+		return err
+	}
+
+	err = t.writeObject(object.Dst, contents, object.FileMode())
+	if err != nil {
+		return err
+	}
+
+	t.manifest.Add(object)
+	t.changed = append(t.changed, object.Dst)
+	t.added = append(t.added, object.Dst)
+	t.files = append(t.files, fmt.Sprintf("%s:%s", object.Src, object.Dst))
+	return nil
+}
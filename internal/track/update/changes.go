@@ -0,0 +1,255 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	git "github.com/libgit2/git2go/v34"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	changeUnmodified = "unmodified"
+	changeModified   = "modified"
+	changeDeleted    = "deleted"
+)
+
+// change is the state at head of a src path since a base commit. Path, Mode
+// and Blob are the head object of a modified, or renamed, path.
+type change struct {
+	Status  string `yaml:"status"`
+	Path    string `yaml:"path,omitempty"`
+	OldMode uint16 `yaml:"old-mode,omitempty"`
+	Mode    uint16 `yaml:"mode,omitempty"`
+	Blob    string `yaml:"blob,omitempty"`
+	// Warning is reported for the paths that can't be updated
+	Warning string `yaml:"warning,omitempty"`
+}
+
+// changes resolves at head the src paths of mapPaths since the base commit.
+// The results are cached per base and head commits, only the paths out of
+// the cache are diffed.
+func (t *Update) changes(repo *git.Repository, base, head string, headTree *git.Tree, mapPaths mapPathObject) (map[string]*change, error) {
+	changes := map[string]*change{}
+	if base == head {
+		return changes, nil
+	}
+
+	cache, err := readChanges(t.dst, base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for path := range mapPaths {
+		if c, ok := cache[path]; ok {
+			changes[path] = c
+		} else {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) == 0 {
+		logTrack.Debug("changes cached", "base", base, "head", head)
+		return changes, nil
+	}
+	sort.Strings(missing)
+
+	oid, err := git.NewOid(base)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.LookupCommit(oid)
+	if err != nil {
+		return nil, err
+	}
+	baseTree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	diffed, err := diffChanges(repo, baseTree, headTree, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, c := range diffed {
+		changes[path] = c
+		cache[path] = c
+	}
+
+	// A dry run or a status leaves the dst repository untouched
+	if t.options.DryRun || t.readOnly {
+		return changes, nil
+	}
+
+	err = writeChanges(t.dst, base, head, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// diffChanges diffs the trees limited to paths. Renames are searched in the
+// full diff only when a path is deleted, the rename detection is slow on
+// large trees.
+func diffChanges(repo *git.Repository, baseTree, headTree *git.Tree, paths []string) (map[string]*change, error) {
+	changes := map[string]*change{}
+	for _, path := range paths {
+		changes[path] = &change{Status: changeUnmodified}
+	}
+
+	diff, err := repo.DiffTreeToTree(baseTree, headTree, &git.DiffOptions{
+		Flags:    git.DiffIncludeTypeChange | git.DiffIncludeTypeChangeTrees | git.DiffDisablePathspecMatch,
+		Pathspec: paths,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := map[string]bool{}
+	err = diff.ForEach(func(delta git.DiffDelta, progress float64) (git.DiffForEachHunkCallback, error) {
+		c, ok := changes[delta.OldFile.Path]
+		if !ok {
+			return nil, nil
+		}
+
+		switch delta.Status {
+		case git.DeltaModified:
+			*c = *modifiedChange(delta)
+
+		case git.DeltaTypeChange:
+			// A file replaced by a folder of the same name is deleted, the
+			// files of the folder are new files. A file turned into a
+			// symbolic link, or back, is a mode change
+			if delta.NewFile.Mode == uint16(git.FilemodeTree) {
+				*c = change{
+					Status:  changeDeleted,
+					OldMode: delta.OldFile.Mode,
+					Warning: "replaced by a folder in src, deleted",
+				}
+			} else {
+				*c = *modifiedChange(delta)
+			}
+
+		case git.DeltaDeleted:
+			*c = change{Status: changeDeleted, OldMode: delta.OldFile.Mode}
+			deleted[delta.OldFile.Path] = true
+
+		case git.DeltaAdded, git.DeltaUnmodified:
+			break
+
+		default:
+			// Ignored, untracked, unreadable or conflicted entries can't be
+			// updated, the objects are kept at their baseline
+			*c = change{
+				Status:  changeUnmodified,
+				Warning: fmt.Sprintf("diff status '%s' not updated", delta.Status),
+			}
+		}
+
+		return nil, nil
+	}, git.DiffDetailFiles)
+	diff.Free()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deleted) == 0 {
+		return changes, nil
+	}
+
+	renames, err := diffRenames(repo, baseTree, headTree, deleted)
+	if err != nil {
+		return nil, err
+	}
+	for path, c := range renames {
+		changes[path] = c
+	}
+
+	return changes, nil
+}
+
+// diffRenames finds the deleted paths renamed in the full diff of the trees.
+func diffRenames(repo *git.Repository, baseTree, headTree *git.Tree, deleted map[string]bool) (map[string]*change, error) {
+	diff, err := repo.DiffTreeToTree(baseTree, headTree, &git.DiffOptions{
+		Flags: git.DiffIncludeTypeChange,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer diff.Free()
+
+	err = diff.FindSimilar(&git.DiffFindOptions{
+		Flags: git.DiffFindRenames,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	renames := map[string]*change{}
+	err = diff.ForEach(func(delta git.DiffDelta, progress float64) (git.DiffForEachHunkCallback, error) {
+		if delta.Status == git.DeltaRenamed && deleted[delta.OldFile.Path] {
+			renames[delta.OldFile.Path] = modifiedChange(delta)
+		}
+		return nil, nil
+	}, git.DiffDetailFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return renames, nil
+}
+
+func modifiedChange(delta git.DiffDelta) *change {
+	return &change{
+		Status:  changeModified,
+		Path:    delta.NewFile.Path,
+		OldMode: delta.OldFile.Mode,
+		Mode:    delta.NewFile.Mode,
+		Blob:    delta.NewFile.Oid.String(),
+	}
+}
+
+func changesPath(dst *git.Repository, base, head string) string {
+	return filepath.Join(dst.Path(), "fhub-track", "diff", fmt.Sprintf("%s-%s.yaml", base, head))
+}
+
+func readChanges(dst *git.Repository, base, head string) (map[string]*change, error) {
+	changes := map[string]*change{}
+
+	data, err := os.ReadFile(changesPath(dst, base, head))
+	if errors.Is(err, os.ErrNotExist) {
+		return changes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, &changes)
+	if err != nil {
+		// A broken cache is diffed again
+		logTrack.Warn("diff cache not read", "base", base, "head", head, "error", err.Error())
+		return map[string]*change{}, nil
+	}
+
+	return changes, nil
+}
+
+func writeChanges(dst *git.Repository, base, head string, changes map[string]*change) error {
+	data, err := yaml.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	path := changesPath(dst, base, head)
+	err = os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,262 @@
+package update
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+	"gopkg.in/yaml.v3"
+)
+
+// conflict is staged in the dst index with the ancestor, ours (dst) and
+// theirs (src) versions of the object.
+type conflict struct {
+	ancestor, ours, theirs *git.IndexEntry
+}
+
+// updateState is an update stopped by conflicts, saved in the dst git
+// directory.
+type updateState struct {
+	// Head is the dst head before the update
+	Head string `yaml:"head"`
+	// Src is the src commit of the update baseline
+	Src     string `yaml:"src"`
+	Message string `yaml:"message"`
+	// Replay is the src commit of a stopped replay step, ReplayHead the dst
+	// head before the replay
+	Replay     string `yaml:"replay,omitempty"`
+	ReplayHead string `yaml:"replay-head,omitempty"`
+}
+
+func statePath(dst *git.Repository) string {
+	return filepath.Join(dst.Path(), "fhub-track", "UPDATE")
+}
+
+func readState(dst *git.Repository) (*updateState, error) {
+	data, err := os.ReadFile(statePath(dst))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &updateState{}
+	err = yaml.Unmarshal(data, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func writeState(dst *git.Repository, s *updateState) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(statePath(dst)), 0750)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(statePath(dst), data, 0644)
+}
+
+// addConflict registers the conflict of path. An object deleted in src has
+// no theirs stage. The src stages of a merged object are transformed by the
+// manifest rules like the merge, so the rules never show as conflicts.
+func (t *Update) addConflict(path string, objectSrc, objectDst *object, transformed bool) error {
+	logTrack.Warn("conflict", "path", path)
+
+	modeAncestor := objectSrc.mode
+	if modeAncestor == 0 {
+		modeAncestor = objectDst.head.mode
+	}
+
+	ancestor, err := t.stageBlob(path, modeAncestor, objectSrc.blob, transformed)
+	if err != nil {
+		return err
+	}
+
+	c := &conflict{
+		ancestor: &git.IndexEntry{
+			Path: path,
+			Mode: git.Filemode(modeAncestor),
+			Id:   ancestor,
+		},
+		ours: &git.IndexEntry{
+			Path: path,
+			Mode: git.Filemode(objectDst.head.mode),
+			Id:   objectDst.head.blob,
+		},
+	}
+	if objectSrc.head != nil {
+		theirs, err := t.stageBlob(path, objectSrc.head.mode, objectSrc.head.blob, transformed)
+		if err != nil {
+			return err
+		}
+
+		c.theirs = &git.IndexEntry{
+			Path: path,
+			Mode: git.Filemode(objectSrc.head.mode),
+			Id:   theirs,
+		}
+	}
+	t.conflicts = append(t.conflicts, c)
+
+	return nil
+}
+
+// stageBlob writes a src blob of a conflict stage in dst, transformed when
+// transformed is set. A dry run only hashes the stage.
+func (t *Update) stageBlob(path string, mode uint16, oid *git.Oid, transformed bool) (*git.Oid, error) {
+	if !transformed {
+		return oid, t.copyBlob(oid)
+	}
+
+	blob, err := t.lookupBlob(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := t.transform(path, mode, blob.Contents())
+	if err != nil {
+		return nil, err
+	}
+
+	if t.options.DryRun {
+		odb, err := t.dst.Odb()
+		if err != nil {
+			return nil, err
+		}
+		return odb.Hash(contents, git.ObjectBlob)
+	}
+
+	return t.dst.CreateBlobFromBuffer(contents)
+}
+
+// copyBlob writes the src blob in dst, a dry run leaves dst untouched.
+func (t *Update) copyBlob(oid *git.Oid) error {
+	if t.options.DryRun {
+		return nil
+	}
+	return utils.CopyBlob(t.src, t.dst, oid)
+}
+
+// Continue commits an update stopped by conflicts, once they are resolved
+// and staged.
+func (t *Update) Continue() error {
+	s, err := readState(t.dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no update in progress")
+	}
+	if err != nil {
+		return err
+	}
+
+	index, err := t.dst.Index()
+	if err != nil {
+		return err
+	}
+
+	paths, err := conflicts(index)
+	if err != nil {
+		return err
+	}
+	if len(paths) > 0 {
+		return &errorPatchConflict{paths}
+	}
+
+	hash, err := git.NewOid(s.Src)
+	if err != nil {
+		return err
+	}
+
+	if s.Replay != "" {
+		oid, err := git.NewOid(s.Replay)
+		if err != nil {
+			return err
+		}
+		t.replayed, err = t.src.LookupCommit(oid)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = t.commitIndex(index, hash, s.Message)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(statePath(t.dst))
+}
+
+// Abort restores the dst head and work tree from before the update, or from
+// before the replay for a stopped replay step.
+func (t *Update) Abort() error {
+	s, err := readState(t.dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no update in progress")
+	}
+	if err != nil {
+		return err
+	}
+
+	head := s.Head
+	if s.ReplayHead != "" {
+		head = s.ReplayHead
+	}
+
+	oid, err := git.NewOid(head)
+	if err != nil {
+		return err
+	}
+
+	commit, err := t.dst.LookupCommit(oid)
+	if err != nil {
+		return err
+	}
+
+	err = t.dst.ResetToCommit(commit, git.ResetHard, &git.CheckoutOptions{
+		Strategy: git.CheckoutForce,
+	})
+	if err != nil {
+		return err
+	}
+
+	logTrack.Info("update aborted", "head", head)
+	return os.Remove(statePath(t.dst))
+}
+
+func conflicts(index *git.Index) ([]string, error) {
+	if !index.HasConflicts() {
+		return nil, nil
+	}
+
+	iterator, err := index.ConflictIterator()
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Free()
+
+	paths := []string{}
+	for {
+		conflict, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrorCodeIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range []*git.IndexEntry{conflict.Our, conflict.Their, conflict.Ancestor} {
+			if entry != nil {
+				paths = append(paths, entry.Path)
+				break
+			}
+		}
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,133 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// preview is a dst object change computed by a dry run, path was oldPath in
+// the work tree when the object is renamed.
+type preview struct {
+	path     string
+	oldPath  string
+	contents []byte
+	deleted  bool
+}
+
+// writeObject writes the object in the dst work tree, a dry run only
+// records the new contents.
+func (t *Update) writeObject(path string, contents []byte, mode git.Filemode) error {
+	if !t.options.DryRun {
+		return utils.WriteObject(filepath.Join(t.dst.Workdir(), path), contents, mode)
+	}
+
+	for _, p := range t.preview {
+		if p.path == path {
+			p.contents = contents
+			return nil
+		}
+	}
+	t.preview = append(t.preview, &preview{path: path, oldPath: path, contents: contents})
+	return nil
+}
+
+func (t *Update) removeObject(path string) error {
+	if !t.options.DryRun {
+		return os.Remove(filepath.Join(t.dst.Workdir(), path))
+	}
+
+	t.preview = append(t.preview, &preview{path: path, oldPath: path, deleted: true})
+	return nil
+}
+
+func (t *Update) renameObject(oldPath, newPath string) error {
+	if !t.options.DryRun {
+		return os.Rename(filepath.Join(t.dst.Workdir(), oldPath), filepath.Join(t.dst.Workdir(), newPath))
+	}
+
+	contents, err := t.readObject(oldPath)
+	if err != nil {
+		return err
+	}
+	t.preview = append(t.preview, &preview{path: newPath, oldPath: oldPath, contents: contents})
+	return nil
+}
+
+// readObject reads a file, or the target of a symbolic link, of the dst work
+// tree. A missing object has no contents.
+func (t *Update) readObject(path string) ([]byte, error) {
+	path = filepath.Join(t.dst.Workdir(), path)
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(target), nil
+	}
+
+	return os.ReadFile(path)
+}
+
+// printPreview prints the unified diff of every object changed by the dry
+// run against the dst work tree, flagging conflicts and deletions.
+func (t *Update) printPreview() error {
+	conflicted := map[string]bool{}
+	for _, conflict := range t.conflicts {
+		conflicted[conflict.ours.Path] = true
+	}
+	added := map[string]bool{}
+	for _, path := range t.added {
+		added[path] = true
+	}
+
+	for _, p := range t.preview {
+		if p.deleted {
+			fmt.Printf("# deleted upstream: %s\n", p.path)
+		} else if conflicted[p.path] {
+			fmt.Printf("# conflict: %s\n", p.path)
+		} else if added[p.path] {
+			fmt.Printf("# added upstream: %s\n", p.path)
+		}
+
+		contents, err := t.readObject(p.oldPath)
+		if err != nil {
+			return err
+		}
+
+		patch, err := t.dst.PatchFromBuffers(p.oldPath, p.path, contents, p.contents, &git.DiffOptions{
+			ContextLines: ContextLines,
+			OldPrefix:    "a",
+			NewPrefix:    "b",
+		})
+		if err != nil {
+			return err
+		}
+
+		diff, err := patch.String()
+		patch.Free()
+		if err != nil {
+			return err
+		}
+		fmt.Print(diff)
+	}
+
+	for _, warning := range t.warnings {
+		fmt.Printf("# warning: %s\n", warning)
+	}
+
+	logTrack.Info("dry run", "objects", len(t.files), "added", len(t.added), "deleted", len(t.deleted), "conflicts", len(t.conflicts))
+	return nil
+}
@@ -1,15 +1,11 @@
 package update
 
 import (
-	"fmt"
-	"strings"
-
 	"github.com/galgotech/fhub-track/internal/track/utils"
 	git "github.com/libgit2/git2go/v34"
 )
 
 type baseObject struct {
-	// repo    string
 	path   string
 	commit string
 
@@ -29,125 +25,70 @@ type object struct {
 }
 
 type listPathObject = []*object
-type mapPathObject = map[string]*object
+type mapPathObject = map[string]listPathObject
 type mapCommitPath = map[string]mapPathObject
 
-func (t *Update) MapObjects() (listPathObject, mapCommitPath, mapCommitPath, error) {
-	head, err := t.dst.Head()
-	if err != nil {
-		return nil, nil, nil, err
-	}
+// MapObjects links the src and dst objects of the manifest tracked from the
+// src repository of the update. The src objects are grouped by baseline
+// commit and src path, the dst objects are listed.
+func (t *Update) MapObjects() (listPathObject, mapCommitPath, error) {
+	objects := listPathObject{}
+	commitsSrc := mapCommitPath{}
 
-	commit, err := t.dst.LookupCommit(head.Target())
+	repo, err := utils.RepoUrl(t.src)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
-	objects := &mapPathObject{}
-	commitsSrc := &mapCommitPath{}
-	commitsDst := &mapCommitPath{}
-	stackCommit := []*git.Commit{commit}
-	for len(stackCommit) > 0 {
-		commit := stackCommit[0]
-		parents := utils.CommitParents(commit)
-		err = t.commitIter(objects, commitsSrc, commitsDst, commit)
-		if err != nil {
-			return nil, nil, nil, err
+	skipped := 0
+	for _, tracked := range t.manifest.Objects {
+		// Objects of other src repositories are left out, the unnamed source
+		// is told apart by the repository url
+		if tracked.Source != t.setting.Source {
+			continue
+		}
+		if tracked.Source == "" && tracked.Repo != "" && !utils.SameRepo(tracked.Repo, repo) {
+			skipped++
+			continue
 		}
 
-		stackCommit = append(stackCommit[1:], parents...)
-	}
+		blob, err := git.NewOid(tracked.Blob)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	listObject := []*object{}
-	for _, object := range *objects {
-		listObject = append(listObject, object)
-	}
+		objSrc := &object{
+			baseObject: baseObject{
+				commit: tracked.Commit,
+				path:   tracked.Src,
+				mode:   uint16(tracked.FileMode()),
+				blob:   blob,
+			},
+			head: &head{},
+		}
 
-	return listObject, *commitsSrc, *commitsDst, nil
-}
+		objDst := &object{
+			baseObject: baseObject{
+				path: tracked.Dst,
+				blob: blob,
+			},
+			head: &head{},
+		}
 
-func (t *Update) commitIter(objects *mapPathObject, commitsSrc, commitsDst *mapCommitPath, commitDst *git.Commit) error {
-	lines := strings.Split(strings.TrimSpace(commitDst.Message()), "\n")
-	if len(lines) < 3 {
-		return nil
-	}
+		objSrc.link = objDst
+		objDst.link = objSrc
 
-	if lines[0] == "fhub-track" || lines[1] == "" {
-		// var repo string
-		var commitOidSrc string
-		commitOidDst := commitDst.Id().String()
-
-		lastKey := ""
-		for _, line := range lines[2:] {
-			line = strings.TrimSpace(line)
-
-			if key, ok := parseMessageKey(line); ok {
-				lastKey = key
-			} else if lastKey == "repo" {
-				// repo = line
-			} else if lastKey == "hash" {
-				var err error
-				commitOidSrc = line
-				if err != nil {
-					return err
-				}
-
-			} else if lastKey == "files" {
-				path := strings.Split(line, ":")
-				if len(path) != 2 {
-					return fmt.Errorf("invalid line '%s'", line)
-				}
-
-				if _, ok := (*commitsSrc)[commitOidSrc]; !ok {
-					(*commitsSrc)[commitOidSrc] = map[string]*object{}
-				}
-
-				if _, ok := (*commitsDst)[commitOidDst]; !ok {
-					(*commitsDst)[commitOidDst] = map[string]*object{}
-				}
-
-				// Add only the first time path find
-				if _, ok := (*objects)[path[1]]; !ok {
-					if _, ok := (*commitsSrc)[commitOidSrc][path[1]]; !ok {
-						objSrc := &object{
-							baseObject: baseObject{
-								commit: commitOidSrc,
-								path:   path[0],
-								// repo:      repo,
-							},
-							head: &head{},
-						}
-
-						objDst := &object{
-							baseObject: baseObject{
-								commit: commitOidDst,
-								path:   path[1],
-								// repo:      repo,
-							},
-							head: &head{},
-						}
-
-						objSrc.link = objDst
-						objDst.link = objSrc
-
-						(*objects)[path[1]] = objDst
-						(*commitsSrc)[commitOidSrc][path[1]] = objSrc
-						(*commitsDst)[commitOidDst][path[1]] = objDst
-					}
-				}
-
-			} else if lastKey == "move" {
-				panic("implement move")
-			}
+		if _, ok := commitsSrc[tracked.Commit]; !ok {
+			commitsSrc[tracked.Commit] = mapPathObject{}
 		}
-	}
+		commitsSrc[tracked.Commit][tracked.Src] = append(commitsSrc[tracked.Commit][tracked.Src], objSrc)
 
-	return nil
-}
+		objects = append(objects, objDst)
+	}
 
-func parseMessageKey(line string) (string, bool) {
-	if line == "repo:" || line == "hash:" || line == "files:" || line == "rename:" {
-		return line[:len(line)-1], true
+	if skipped > 0 {
+		logTrack.Warn("objects of another repository skipped, name the sources with source add", "repo", repo, "skipped", skipped)
 	}
-	return "", false
+
+	return objects, commitsSrc, nil
 }
@@ -0,0 +1,190 @@
+package update
+
+import (
+	"errors"
+
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/utils"
+	git "github.com/libgit2/git2go/v34"
+)
+
+// replay applies, from the oldest, every src commit between the baselines
+// and the target that changed a tracked path. Each commit is applied to the
+// objects whose baseline is its ancestor. A conflict stops the replay at its
+// commit, once continued the replay is resumed by running it again.
+func (t *Update) replay() error {
+	if t.options.DryRun || t.options.NoCommit {
+		return errors.New("replay can't be used with dry run or no commit")
+	}
+
+	commits, err := t.replayCommits()
+	if err != nil {
+		return err
+	}
+
+	logTrack.Info("replay", "commits", len(commits))
+
+	head, err := utils.HeadCommit(t.dst)
+	if err != nil {
+		return err
+	}
+	t.replayHead = head.Id().String()
+
+	for _, commit := range commits {
+		logTrack.Info("replay commit", "commit", commit.Id().String(), "summary", commit.Summary())
+
+		t.options.To = commit.Id().String()
+		t.replayed = commit
+		t.ancestors = map[string]bool{}
+		t.changed = nil
+		t.files = nil
+		t.added = nil
+		t.deleted = nil
+		t.untracked = nil
+		t.warnings = nil
+		t.conflicts = nil
+
+		err := t.apply()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replayCommits lists the src commits to replay in topological order.
+func (t *Update) replayCommits() ([]*git.Commit, error) {
+	m, err := manifest.Load(t.src, t.dst)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := t.target()
+	if err != nil {
+		return nil, err
+	}
+
+	walk, err := t.src.Walk()
+	if err != nil {
+		return nil, err
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTopological | git.SortReverse)
+	err = walk.Push(target.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := utils.RepoUrl(t.src)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	baselines := map[string]*git.Oid{}
+	for _, object := range m.Objects {
+		if object.Source != t.setting.Source {
+			continue
+		}
+		if object.Source == "" && object.Repo != "" && !utils.SameRepo(object.Repo, repo) {
+			continue
+		}
+		if object.Commit == "" {
+			return nil, errors.New("replay needs the baseline commit of every object, run a plain update first")
+		}
+		paths = append(paths, object.Src)
+		baselines[object.Commit], err = git.NewOid(object.Commit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, track := range m.Tracks {
+		if track.Source != t.setting.Source {
+			continue
+		}
+		paths = append(paths, track.Src)
+	}
+
+	// The commits before every baseline are not replayed, a commit between
+	// the baselines is replayed to the objects of the older ones
+	oids := []*git.Oid{}
+	for _, oid := range baselines {
+		oids = append(oids, oid)
+	}
+	if len(oids) > 0 {
+		base := oids[0]
+		if len(oids) > 1 {
+			base, err = t.src.MergeBaseOctopus(oids)
+		}
+		if err == nil {
+			err = walk.Hide(base)
+		}
+		if err != nil && !git.IsErrorCode(err, git.ErrorCodeNotFound) {
+			return nil, err
+		}
+	}
+
+	commits := []*git.Commit{}
+	var errIterate error
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		descendant := false
+		for _, oid := range oids {
+			descendant, errIterate = t.src.DescendantOf(commit.Id(), oid)
+			if errIterate != nil {
+				return false
+			}
+			if descendant {
+				break
+			}
+		}
+		if !descendant {
+			return true
+		}
+
+		touched, err := utils.Touches(t.src, commit, paths)
+		if err != nil {
+			errIterate = err
+			return false
+		}
+		if touched {
+			commits = append(commits, commit)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errIterate != nil {
+		return nil, errIterate
+	}
+
+	return commits, nil
+}
+
+// replays reports whether the replayed commit is applied to the object, its
+// baseline must be an ancestor of the commit. Every object is updated out of
+// a replay.
+func (t *Update) replays(objectSrc *object) (bool, error) {
+	if t.replayed == nil {
+		return true, nil
+	}
+
+	if ancestor, ok := t.ancestors[objectSrc.commit]; ok {
+		return ancestor, nil
+	}
+
+	oid, err := git.NewOid(objectSrc.commit)
+	if err != nil {
+		return false, err
+	}
+
+	ancestor, err := t.src.DescendantOf(t.replayed.Id(), oid)
+	if err != nil {
+		return false, err
+	}
+
+	t.ancestors[objectSrc.commit] = ancestor
+	return ancestor, nil
+}
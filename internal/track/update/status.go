@@ -0,0 +1,129 @@
+package update
+
+import (
+	"sort"
+
+	"github.com/galgotech/fhub-track/internal/track/utils"
+)
+
+type State string
+
+const (
+	StatePristine        State = "pristine"
+	StateModifiedLocally State = "modified locally"
+	StateChangedUpstream State = "changed upstream"
+	StateModeUpstream    State = "mode changed upstream"
+	StateChangedBoth     State = "changed on both sides"
+	StateDeletedUpstream State = "deleted upstream"
+	StateDeletedLocally  State = "deleted locally"
+	StateAddedUpstream   State = "added upstream"
+)
+
+type ObjectStatus struct {
+	Src    string
+	Dst    string
+	Commit string
+	State  State
+	// Uncommitted is set when the dst object has changes in the index or the
+	// work tree, the state compares the dst head
+	Uncommitted bool
+}
+
+// Status classifies the drift since its baseline of every object tracked from
+// the src repository of the update, the objects of other sources are not
+// listed. dst is not changed.
+func (t *Update) Status() ([]*ObjectStatus, error) {
+	t.readOnly = true
+
+	mapObjects, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+
+	modified, err := utils.Modified(t.dst)
+	if err != nil {
+		return nil, err
+	}
+	uncommitted := map[string]bool{}
+	for _, path := range modified {
+		uncommitted[path] = true
+	}
+
+	status := make([]*ObjectStatus, len(mapObjects))
+	for i, objectDst := range mapObjects {
+		objectSrc := objectDst.link
+		state, err := t.state(objectSrc, objectDst)
+		if err != nil {
+			return nil, err
+		}
+
+		// Local edits not committed yet are changes of dst too
+		if uncommitted[objectDst.path] {
+			switch state {
+			case StatePristine:
+				state = StateModifiedLocally
+			case StateChangedUpstream, StateModeUpstream:
+				state = StateChangedBoth
+			}
+		}
+
+		status[i] = &ObjectStatus{
+			Src:         objectSrc.path,
+			Dst:         objectDst.path,
+			Commit:      objectSrc.commit,
+			State:       state,
+			Uncommitted: uncommitted[objectDst.path],
+		}
+	}
+
+	newObjects, err := t.newObjects(mapObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range newObjects {
+		status = append(status, &ObjectStatus{
+			Src:    object.Src,
+			Dst:    object.Dst,
+			Commit: object.Commit,
+			State:  StateAddedUpstream,
+		})
+	}
+
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Dst < status[j].Dst
+	})
+
+	return status, nil
+}
+
+// state compares the objects with the baseline, the dst object with the
+// baseline transformed by the manifest rules.
+func (t *Update) state(objectSrc, objectDst *object) (State, error) {
+	if objectDst.head == nil {
+		return StateDeletedLocally, nil
+	}
+	if objectSrc.head == nil {
+		return StateDeletedUpstream, nil
+	}
+
+	changedSrc := !objectSrc.head.blob.Equal(objectSrc.blob) || objectSrc.head.path != objectSrc.path
+	changedMode := objectSrc.mode != 0 && objectSrc.head.mode != objectSrc.mode
+	changedDst, err := t.modifiedDst(objectSrc, objectDst)
+	if err != nil {
+		return "", err
+	}
+
+	switch true {
+	case (changedSrc || changedMode) && changedDst:
+		return StateChangedBoth, nil
+	case changedSrc:
+		return StateChangedUpstream, nil
+	case changedMode:
+		return StateModeUpstream, nil
+	case changedDst:
+		return StateModifiedLocally, nil
+	default:
+		return StatePristine, nil
+	}
+}
@@ -1,93 +1,381 @@
 package update
 
 import (
+	"errors"
 	"fmt"
-	"io/fs"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/galgotech/fhub-track/internal/log"
 	"github.com/galgotech/fhub-track/internal/setting"
+	"github.com/galgotech/fhub-track/internal/track/manifest"
+	"github.com/galgotech/fhub-track/internal/track/transform"
+	"github.com/galgotech/fhub-track/internal/track/utils"
 	git "github.com/libgit2/git2go/v34"
 )
 
 const ContextLines = 5
 
-// errorString is a trivial implementation of error.
+// errorPatchConflict lists the objects left with conflicts by an update.
 type errorPatchConflict struct {
-	patch string
+	paths []string
 }
 
 func (e *errorPatchConflict) Error() string {
-	return "patch conflict"
+	return fmt.Sprintf(
+		"patch conflict in %s, resolve and stage them then run update --continue, or run update --abort",
+		strings.Join(e.paths, ", "),
+	)
 }
 
-func New(setting *setting.Setting, src *git.Repository, dst *git.Repository) *Update {
+// RenamePolicy decides how an object renamed in src is updated in dst.
+type RenamePolicy string
+
+const (
+	// RenameKeep keeps the dst path and points the object to the new src path
+	RenameKeep RenamePolicy = "keep"
+	// RenameFollow renames the dst path to match the new src path
+	RenameFollow RenamePolicy = "follow"
+)
+
+// DeletePolicy decides how an object deleted in src and modified in dst is
+// updated.
+type DeletePolicy string
+
+const (
+	// DeleteKeep keeps the dst object and untracks it
+	DeleteKeep DeletePolicy = "keep"
+	// DeleteRemove deletes the dst object with its local changes
+	DeleteRemove DeletePolicy = "delete"
+	// DeleteStop registers a modify/delete conflict and stops the update
+	DeleteStop DeletePolicy = "stop"
+)
+
+type Options struct {
+	Renames RenamePolicy
+	Deletes DeletePolicy
+	// NoCommit leaves the update staged in the dst index
+	NoCommit bool
+	// To is the src revision, or date, of the update, src HEAD by default
+	To string
+	// Replay applies every src commit that changed tracked objects as its own
+	// dst commit
+	Replay bool
+	// DryRun computes the update in memory and prints it as a diff
+	DryRun bool
+}
+
+func New(setting *setting.Setting, src *git.Repository, dst *git.Repository, options Options) *Update {
+	if options.Renames == "" {
+		options.Renames = RenameKeep
+	}
+	if options.Deletes == "" {
+		options.Deletes = DeleteStop
+	}
+
 	return &Update{
 		setting: setting,
 		src:     src,
 		dst:     dst,
+		options: options,
 	}
 }
 
 var logTrack = log.New("track-update")
 
 type Update struct {
-	setting *setting.Setting
-	src     *git.Repository
-	dst     *git.Repository
+	setting  *setting.Setting
+	src      *git.Repository
+	dst      *git.Repository
+	options  Options
+	manifest *manifest.Manifest
+	headSrc  *git.Commit
+
+	// Work tree paths changed, and the objects updated or deleted for the
+	// commit message
+	changed   []string
+	files     []string
+	added     []string
+	deleted   []string
+	untracked []string
+	warnings  []string
+	conflicts []*conflict
+	preview   []*preview
+	// replayed is the src commit applied by a replay step, replayHead the
+	// dst head before the replay and ancestors whether each baseline is an
+	// ancestor of replayed
+	replayed   *git.Commit
+	replayHead string
+	ancestors  map[string]bool
+	// readOnly leaves the dst repository untouched, as a dry run
+	readOnly bool
 }
 
 func (t *Update) Run() error {
 	logTrack.Debug("start update")
 
-	headSrc, err := t.src.Head()
+	if t.options.Renames != RenameKeep && t.options.Renames != RenameFollow {
+		return fmt.Errorf("invalid rename policy '%s'", t.options.Renames)
+	}
+	if t.options.Deletes != DeleteKeep && t.options.Deletes != DeleteRemove && t.options.Deletes != DeleteStop {
+		return fmt.Errorf("invalid delete policy '%s'", t.options.Deletes)
+	}
+
+	if _, err := readState(t.dst); err == nil {
+		return errors.New("an update is in progress, run update --continue or update --abort")
+	}
+
+	modified, err := utils.Modified(t.dst)
 	if err != nil {
-		logTrack.Error("head reference", "repo", "src")
 		return err
 	}
-	headDst, err := t.dst.Head()
+	if len(modified) > 0 {
+		return fmt.Errorf("the destination repository has uncommitted changes (%s)", strings.Join(modified, ", "))
+	}
+
+	if t.options.Replay {
+		return t.replay()
+	}
+
+	return t.apply()
+}
+
+// apply updates the dst objects to the target src commit and commits them.
+func (t *Update) apply() error {
+	mapObjects, err := t.load()
 	if err != nil {
-		logTrack.Error("head reference", "repo", "dst")
 		return err
 	}
 
-	headCommitOidSrc := headSrc.Target()
-	headCommitOidDst := headDst.Target()
+	for _, objectDst := range mapObjects {
+		objectSrc := objectDst.link
+		replayed, err := t.replays(objectSrc)
+		if err != nil {
+			return err
+		}
+		if !replayed {
+			continue
+		}
 
-	logTrack.Info("map objects")
-	mapObjects, mapCommitsSrc, mapCommitsDst, err := t.MapObjects()
+		logTrack.Info("update", "path", objectDst.path)
+		err = t.updateObject(objectSrc, objectDst)
+		if err != nil {
+			return err
+		}
+	}
+
+	newObjects, err := t.newObjects(mapObjects)
 	if err != nil {
 		return err
 	}
 
-	logTrack.Debug("objects", "count", len(mapObjects))
+	for _, object := range newObjects {
+		logTrack.Info("add", "path", object.Dst, "src", object.Src)
+		err := t.addObject(object)
+		if err != nil {
+			return err
+		}
+	}
 
-	logTrack.Info("load blob", "repo", "src")
-	err = t.blob(t.src, mapCommitsSrc, headCommitOidSrc)
+	if t.options.DryRun {
+		return t.printPreview()
+	}
+
+	err = t.manifest.Write(t.dst)
 	if err != nil {
 		return err
 	}
 
-	logTrack.Info("load blob", "repo", "dst")
-	err = t.blob(t.dst, mapCommitsDst, headCommitOidDst)
+	return t.commit()
+}
+
+// commit stages the changed objects with the manifest and commits them with
+// the src head as baseline, unless the update is left staged. Conflicts are
+// staged and the update stops until it is continued or aborted.
+func (t *Update) commit() error {
+	index, err := t.dst.Index()
 	if err != nil {
 		return err
 	}
 
-	for _, objectDst := range mapObjects {
-		logTrack.Info("update", "path", objectDst.path)
-		objectSrc := objectDst.link
-		err := t.updateObject(objectSrc, objectDst)
+	for _, path := range append(t.changed, manifest.FileName) {
+		if _, err := os.Lstat(filepath.Join(t.dst.Workdir(), path)); err == nil {
+			err = index.AddByPath(path)
+		} else {
+			err = index.RemoveByPath(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	paths := []string{}
+	for _, conflict := range t.conflicts {
+		err = index.AddConflict(conflict.ancestor, conflict.ours, conflict.theirs)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, conflict.ours.Path)
+	}
+
+	err = index.Write()
+	if err != nil {
+		return err
+	}
+
+	msg := ""
+	if len(t.files) > 0 {
+		msg += fmt.Sprintf("files:\n  %s\n", strings.Join(t.files, "\n  "))
+	}
+	if len(t.deleted) > 0 {
+		msg += fmt.Sprintf("delete:\n  %s\n", strings.Join(t.deleted, "\n  "))
+	}
+	if len(t.untracked) > 0 {
+		msg += fmt.Sprintf("untrack:\n  %s\n", strings.Join(t.untracked, "\n  "))
+	}
+
+	logTrack.Info("update", "objects", len(t.files), "added", len(t.added), "deleted", len(t.deleted), "untracked", len(t.untracked), "conflicts", len(t.conflicts))
+	for _, path := range t.added {
+		logTrack.Info("added upstream", "path", path)
+	}
+	for _, warning := range t.warnings {
+		logTrack.Warn("update warning", "warning", warning)
+	}
+
+	if len(paths) > 0 {
+		commitHead, err := utils.HeadCommit(t.dst)
+		if err != nil {
+			return err
+		}
+
+		state := &updateState{
+			Head:    commitHead.Id().String(),
+			Src:     t.headSrc.Id().String(),
+			Message: msg,
+		}
+		if t.replayed != nil {
+			state.Replay = t.replayed.Id().String()
+			state.ReplayHead = t.replayHead
+		}
+
+		err = writeState(t.dst, state)
 		if err != nil {
 			return err
 		}
+
+		return &errorPatchConflict{paths}
 	}
 
+	return t.commitIndex(index, t.headSrc.Id(), msg)
+}
+
+// commitIndex commits the dst index with the src commit hash as baseline.
+func (t *Update) commitIndex(index *git.Index, hash *git.Oid, msg string) error {
+	treeOid, err := index.WriteTree()
+	if err != nil {
+		return err
+	}
+
+	commitHead, err := utils.HeadCommit(t.dst)
+	if err != nil {
+		return err
+	}
+
+	if treeOid.Equal(commitHead.TreeId()) {
+		logTrack.Info("already up to date")
+		return nil
+	}
+
+	if t.options.NoCommit {
+		logTrack.Info("update staged")
+		return nil
+	}
+
+	tree, err := t.dst.LookupTree(treeOid)
+	if err != nil {
+		return err
+	}
+
+	if t.replayed != nil {
+		_, err = utils.ReplayCommit(t.dst, "HEAD", t.replayed, tree, commitHead)
+	} else {
+		_, err = utils.Commit(t.src, t.dst, hash, msg, tree, commitHead)
+	}
+	if err != nil {
+		return err
+	}
+
+	logTrack.Info("update committed")
 	return nil
 }
 
+// load maps the objects of the manifest and resolves their state in the
+// src and dst heads.
+func (t *Update) load() (listPathObject, error) {
+	var err error
+	t.headSrc, err = t.target()
+	if err != nil {
+		logTrack.Error("target commit", "repo", "src", "to", t.options.To)
+		return nil, err
+	}
+	headDst, err := t.dst.Head()
+	if err != nil {
+		logTrack.Error("head reference", "repo", "dst")
+		return nil, err
+	}
+
+	headCommitOidSrc := t.headSrc.Id()
+	headCommitOidDst := headDst.Target()
+
+	t.manifest, err = manifest.Load(t.src, t.dst)
+	if err != nil {
+		return nil, err
+	}
+
+	logTrack.Info("map objects")
+	mapObjects, mapCommitsSrc, err := t.MapObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	logTrack.Debug("objects", "count", len(mapObjects))
+
+	logTrack.Info("load blob", "repo", "src")
+	err = t.blob(t.src, mapCommitsSrc, headCommitOidSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	logTrack.Info("load blob", "repo", "dst")
+	err = t.blobDst(mapObjects, headCommitOidDst)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapObjects, nil
+}
+
+// target resolves the src commit of the update. A date selects the newest
+// commit of src HEAD committed until it.
+func (t *Update) target() (*git.Commit, error) {
+	if t.options.To == "" {
+		return utils.HeadCommit(t.src)
+	}
+
+	commit, err := utils.RevCommit(t.src, t.options.To)
+	if err == nil {
+		return commit, nil
+	}
+
+	date, errDate := utils.ParseDate(t.options.To)
+	if errDate != nil {
+		return nil, err
+	}
+
+	return utils.DateCommit(t.src, date)
+}
+
 func (t *Update) blob(repo *git.Repository, mapObjects mapCommitPath, headCommitOid *git.Oid) error {
 	headCommit, err := repo.LookupCommit(headCommitOid)
 	if err != nil {
@@ -103,94 +391,96 @@ func (t *Update) blob(repo *git.Repository, mapObjects mapCommitPath, headCommit
 	for commitOid, mapPaths := range mapObjects {
 		logTrack.Info("blob current commit", "repoPath", repo.Path(), "commit", commitOid, "head", headCommitOid.String())
 
-		oid, err := git.NewOid(commitOid)
-		if err != nil {
-			return err
+		// Baseline unknown, only the path is compared with head
+		if commitOid == "" {
+			for path, objects := range mapPaths {
+				entry, err := headTree.EntryByPath(path)
+				for _, object := range objects {
+					if err != nil {
+						object.head = nil
+						continue
+					}
+					object.head.commit = headCommitOid.String()
+					object.head.path = path
+					object.head.mode = uint16(entry.Filemode)
+					object.head.blob = entry.Id
+				}
+			}
+			continue
 		}
 
-		commit, err := repo.LookupCommit(oid)
-		if err != nil {
-			return err
-		}
-		oldTree, err := commit.Tree()
+		changes, err := t.changes(repo, commitOid, headCommitOid.String(), headTree, mapPaths)
 		if err != nil {
 			return err
 		}
 
-		diff, err := repo.DiffTreeToTree(oldTree, headTree, &git.DiffOptions{
-			Flags: git.DiffMinimal | git.DiffIncludeTypeChange | git.DiffIncludeTypeChangeTrees,
-			// IgnoreSubmodules SubmoduleIgnore
-			// Pathspec         []string
-			// NotifyCallback   DiffNotifyCallback
-
-			ContextLines: 5,
-			// InterhunkLines uint32
-			// IdAbbrev       uint16
-
-			// MaxSize int
-			OldPrefix: "a",
-			NewPrefix: "b",
-		})
-		if err != nil {
-			return err
-		}
+		for path, change := range changes {
+			if change.Warning != "" {
+				t.warn(path, change.Warning)
+			}
 
-		// Rename detection (slow operation with many commits diff)
-		err = diff.FindSimilar(&git.DiffFindOptions{
-			Flags: git.DiffFindRenames | git.DiffFindCopies | git.DiffFindForUntracked,
-		})
-		if err != nil {
-			return err
-		}
-		diff.ForEach(func(delta git.DiffDelta, progress float64) (git.DiffForEachHunkCallback, error) {
-			switch true {
-			case delta.Status == git.DeltaModified:
-				if object, ok := mapPaths[delta.OldFile.Path]; ok {
-					object.mode = delta.OldFile.Mode
-					object.blob = delta.OldFile.Oid
+			for _, object := range mapPaths[path] {
+				switch change.Status {
+				case changeModified:
+					object.mode = change.OldMode
 
 					object.head.commit = headCommitOid.String()
-					object.head.path = delta.NewFile.Path
-					object.head.mode = delta.NewFile.Mode
-					object.head.blob = delta.NewFile.Oid
-				}
-
-			case delta.Status == git.DeltaAdded:
-				// Ignore added files
-				break
+					object.head.path = change.Path
+					object.head.mode = change.Mode
+					object.head.blob, err = git.NewOid(change.Blob)
+					if err != nil {
+						return err
+					}
 
-			case delta.Status == git.DeltaDeleted:
-				if object, ok := mapPaths[delta.OldFile.Path]; ok {
-					object.mode = delta.OldFile.Mode
-					object.blob = delta.OldFile.Oid
+				case changeDeleted:
+					object.mode = change.OldMode
 
 					object.head = nil
 				}
+			}
+		}
 
-			case delta.Status == git.DeltaRenamed || delta.Status == git.DeltaCopied:
-				if object, ok := mapPaths[delta.OldFile.Path]; ok {
-					object.mode = delta.OldFile.Mode
-					object.blob = delta.OldFile.Oid
-
+		// Objects out of the diff are unmodified since the baseline
+		for _, objects := range mapPaths {
+			for _, object := range objects {
+				if object.head != nil && object.head.blob == nil {
+					object.head.baseObject = object.baseObject
 					object.head.commit = headCommitOid.String()
-					object.head.path = delta.NewFile.Path
-					object.head.mode = delta.NewFile.Mode
-					object.head.blob = delta.NewFile.Oid
 				}
-
-			// case git.DeltaUnmodified
-			// case git.DeltaIgnored:
-			// case git.DeltaUntracked:
-			// case git.DeltaTypeChange:
-			// case git.DeltaUnreadable:
-			// case git.DeltaConflicted:
-			default:
-				return nil, fmt.Errorf("not implemented diff delta status '%s' '%s' '%s'", delta.Status, delta.NewFile.Path, delta.OldFile.Path)
 			}
+		}
+	}
+	return nil
+}
 
-			return nil, nil
-		}, git.DiffDetailFiles)
+func (t *Update) blobDst(objects listPathObject, headCommitOid *git.Oid) error {
+	headCommit, err := t.dst.LookupCommit(headCommitOid)
+	if err != nil {
+		logTrack.Error("head lookup commit", "repo", "dst")
+		return err
 	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		logTrack.Error("head tree", "repo", "dst")
+		return err
+	}
+
+	for _, object := range objects {
+		entry, err := headTree.EntryByPath(object.path)
+		if err != nil {
+			object.head = nil
+			continue
+		}
+
+		object.commit = headCommitOid.String()
+		object.mode = uint16(entry.Filemode)
+
+		object.head.commit = headCommitOid.String()
+		object.head.path = object.path
+		object.head.mode = uint16(entry.Filemode)
+		object.head.blob = entry.Id
+	}
+
 	return nil
 }
 
@@ -202,76 +492,284 @@ func (t *Update) updateObject(objectSrc, objectDst *object) (err error) {
 	}
 
 	if objectSrc.head == nil {
-		pathRemove := filepath.Join(t.setting.DstRepo, path)
-		logTrack.Info("deleting object", "path", pathRemove)
-		err := os.Remove(pathRemove)
-		if err != nil {
-			return err
+		return t.deleteObject(objectSrc, objectDst)
+	}
+
+	tracked := t.manifest.Find(path)
+	tracked.Src = objectSrc.head.path
+	tracked.Commit = objectSrc.head.commit
+
+	renamed := objectSrc.head.path != objectSrc.path
+	if renamed {
+		logTrack.Info("renamed", "path", path, "src", objectSrc.path, "head", objectSrc.head.path, "policy", t.options.Renames)
+		if t.options.Renames == RenameFollow {
+			newPath := t.renamedDst(objectSrc, path)
+			err := t.moveObject(path, newPath)
+			if err != nil {
+				return err
+			}
+			t.changed = append(t.changed, path, newPath)
+			tracked.Dst = newPath
+			path = newPath
 		}
-		return nil
 	}
 
-	if objectSrc.blob == nil {
+	// Objects tracked without mode have no baseline mode to compare
+	modeChanged := objectSrc.mode != 0 && objectSrc.head.mode != objectSrc.mode
+	if modeChanged {
+		logTrack.Info("mode changed", "path", path, "repo", "src",
+			"old", manifest.FormatMode(git.Filemode(objectSrc.mode)), "new", manifest.FormatMode(git.Filemode(objectSrc.head.mode)))
+	}
+
+	if objectSrc.head.blob.Equal(objectSrc.blob) && !modeChanged {
 		logTrack.Info("unmodified", "path", path, "repo", "src")
+		if renamed {
+			t.files = append(t.files, fmt.Sprintf("%s:%s", tracked.Src, tracked.Dst))
+		}
 		return nil
 	}
-	if objectDst.blob == nil {
-		logTrack.Info("unmodified", "path", path, "repo", "dst")
-		return nil
+
+	// The src mode wins when it changed, otherwise the dst mode is kept
+	mode := git.Filemode(objectDst.head.mode)
+	if modeChanged {
+		mode = git.Filemode(objectSrc.head.mode)
 	}
 
-	blobAncestor, err := t.src.LookupBlob(objectSrc.blob)
+	modifiedDst, err := t.modifiedDst(objectSrc, objectDst)
 	if err != nil {
 		return err
 	}
-	oursBlob, err := t.src.LookupBlob(objectSrc.head.blob)
+
+	var contents []byte
+	if !modifiedDst {
+		logTrack.Info("unmodified", "path", path, "repo", "dst")
+		oursBlob, err := t.src.LookupBlob(objectSrc.head.blob)
+		if err != nil {
+			return err
+		}
+		contents, err = t.transform(path, objectSrc.head.mode, oursBlob.Contents())
+		if err != nil {
+			return err
+		}
+	} else {
+		theirsBlob, err := t.dst.LookupBlob(objectDst.head.blob)
+		if err != nil {
+			return err
+		}
+		contents = theirsBlob.Contents()
+
+		// When only the mode changed in src the dst contents are kept
+		changedSrc := !objectSrc.head.blob.Equal(objectSrc.blob)
+		link := objectSrc.head.mode == uint16(git.FilemodeLink) || objectDst.head.mode == uint16(git.FilemodeLink)
+		if link && (changedSrc || objectSrc.head.mode != objectDst.head.mode) {
+			// Symbolic links are not merged, dst is kept in the work tree
+			// with its type, a file is not written as a link or the reverse
+			mode = git.Filemode(objectDst.head.mode)
+			err := t.addConflict(path, objectSrc, objectDst, false)
+			if err != nil {
+				return err
+			}
+		} else if changedSrc {
+			oursBlob, err := t.src.LookupBlob(objectSrc.head.blob)
+			if err != nil {
+				return err
+			}
+			blobAncestor, err := t.lookupBlob(objectSrc.blob)
+			if err != nil {
+				return err
+			}
+
+			// The rules are applied to both src versions, so they never conflict
+			ancestorContents, err := t.transform(path, objectSrc.mode, blobAncestor.Contents())
+			if err != nil {
+				return err
+			}
+			oursContents, err := t.transform(path, objectSrc.head.mode, oursBlob.Contents())
+			if err != nil {
+				return err
+			}
+
+			ancestorFile := git.MergeFileInput{
+				Path:     path,
+				Mode:     0,
+				Contents: ancestorContents,
+			}
+			oursFile := git.MergeFileInput{
+				Path:     path,
+				Mode:     0,
+				Contents: oursContents,
+			}
+			theirsFile := git.MergeFileInput{
+				Path:     path,
+				Mode:     0,
+				Contents: contents,
+			}
+
+			mergeResult, err := git.MergeFile(ancestorFile, oursFile, theirsFile, &git.MergeFileOptions{
+				AncestorLabel: fmt.Sprintf("ancestor %s", objectSrc.commit),
+				OurLabel:      fmt.Sprintf("src %s", objectSrc.head.commit),
+				TheirLabel:    fmt.Sprintf("dst %s", objectDst.commit),
+				Favor:         git.MergeFileFavorNormal,
+				Flags:         git.MergeFileDiffPatience,
+				//  MarkerSize    uint16
+			})
+			if err != nil {
+				return err
+			}
+			contents = mergeResult.Contents
+
+			if !mergeResult.Automergeable {
+				err := t.addConflict(path, objectSrc, objectDst, true)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	tracked.Blob = objectSrc.head.blob.String()
+	tracked.Mode = manifest.FormatMode(git.Filemode(objectSrc.head.mode))
+
+	t.changed = append(t.changed, path)
+	t.files = append(t.files, fmt.Sprintf("%s:%s", tracked.Src, tracked.Dst))
+
+	return t.writeObject(path, contents, mode)
+}
+
+// deleteObject updates an object deleted in src. The deletion is staged and
+// the object removed from the manifest, a dst object with local changes is
+// handled by the delete policy.
+func (t *Update) deleteObject(objectSrc, objectDst *object) error {
+	path := objectDst.path
+
+	modifiedDst, err := t.modifiedDst(objectSrc, objectDst)
 	if err != nil {
 		return err
 	}
-	theirsBlob, err := t.dst.LookupBlob(objectDst.head.blob)
+
+	if modifiedDst && t.options.Deletes != DeleteRemove {
+		logTrack.Warn("deleted in src and modified in dst", "path", path, "policy", t.options.Deletes)
+
+		if t.options.Deletes == DeleteKeep {
+			t.manifest.Untrack(path)
+			t.untracked = append(t.untracked, path)
+			return nil
+		}
+
+		// The object is no longer tracked, resolving the conflict keeps or
+		// deletes the dst object
+		t.manifest.Remove(path)
+		if t.options.DryRun {
+			contents, err := t.readObject(path)
+			if err != nil {
+				return err
+			}
+			t.preview = append(t.preview, &preview{path: path, oldPath: path, contents: contents})
+		}
+		t.changed = append(t.changed, path)
+		return t.addConflict(path, objectSrc, objectDst, true)
+	}
+
+	logTrack.Info("deleting object", "path", path)
+	err = t.removeObject(path)
 	if err != nil {
 		return err
 	}
+	t.manifest.Remove(path)
+	t.changed = append(t.changed, path)
+	t.deleted = append(t.deleted, path)
+	return nil
+}
 
-	ancestorFile := git.MergeFileInput{
-		Path:     path,
-		Mode:     0,
-		Contents: blobAncestor.Contents(),
+// modifiedDst reports whether the dst object has local changes since the
+// baseline, the baseline transformed by the manifest rules.
+func (t *Update) modifiedDst(objectSrc, objectDst *object) (bool, error) {
+	if objectDst.head.blob.Equal(objectDst.blob) {
+		return false, nil
 	}
-	oursFile := git.MergeFileInput{
-		Path:     path,
-		Mode:     0,
-		Contents: oursBlob.Contents(),
+	if len(t.manifest.Imports) == 0 && len(t.manifest.Transforms) == 0 {
+		return true, nil
 	}
-	theirsFile := git.MergeFileInput{
-		Path:     path,
-		Mode:     0,
-		Contents: theirsBlob.Contents(),
+
+	blob, err := t.lookupBlob(objectSrc.blob)
+	if err != nil {
+		return false, err
+	}
+	contents, err := t.transform(objectDst.path, objectSrc.mode, blob.Contents())
+	if err != nil {
+		return false, err
 	}
 
-	mergeResult, err := git.MergeFile(ancestorFile, oursFile, theirsFile, &git.MergeFileOptions{
-		AncestorLabel: fmt.Sprintf("ancestor %s", objectDst.commit),
-		OurLabel:      fmt.Sprintf("src %s", objectSrc.commit),
-		TheirLabel:    fmt.Sprintf("dst %s", objectDst.commit),
-		Favor:         git.MergeFileFavorNormal,
-		Flags:         git.MergeFileDiffPatience,
-		//  MarkerSize    uint16
-	})
+	odb, err := t.dst.Odb()
 	if err != nil {
-		return err
+		return false, err
+	}
+	oid, err := odb.Hash(contents, git.ObjectBlob)
+	if err != nil {
+		return false, err
 	}
 
-	// var pathSave string
-	// if objectSrc.path != objectSrc.head.path {
-	// 	// contents = fmt.Sprintf("%b->%b%b", objectDst.path, objectDst.link, contents)
-	// } else {
-	// 	pathSave = filepath.Join(t.setting.DstRepo, path)
-	// }
+	return !oid.Equal(objectDst.head.blob), nil
+}
 
-	path = filepath.Join(t.setting.DstRepo, path)
-	err = ioutil.WriteFile(path, mergeResult.Contents, fs.FileMode(objectDst.mode))
-	if err != nil {
-		return err
+// warn records a warning of the src object path for the update report.
+func (t *Update) warn(path, msg string) {
+	logTrack.Warn(msg, "path", path)
+	t.warnings = append(t.warnings, fmt.Sprintf("%s: %s", path, msg))
+}
+
+// renamedDst maps the new src path of a renamed object to dst, with the
+// tracking rule of the object or, out of it, in the same dst folder.
+func (t *Update) renamedDst(objectSrc *object, path string) string {
+	for _, track := range t.manifest.Tracks {
+		if track.Source == t.setting.Source && track.DstPath(objectSrc.path) == path && manifest.IsSubPath(track.Src, objectSrc.head.path) {
+			return track.DstPath(objectSrc.head.path)
+		}
 	}
-	return nil
+	return filepath.Join(filepath.Dir(path), filepath.Base(objectSrc.head.path))
+}
+
+func (t *Update) moveObject(oldPath, newPath string) error {
+	if oldPath == newPath {
+		return nil
+	}
+
+	if t.manifest.Find(newPath) != nil {
+		return fmt.Errorf("rename '%s' to '%s', the path is already tracked", oldPath, newPath)
+	}
+
+	newObject := filepath.Join(t.dst.Workdir(), newPath)
+	if _, err := os.Lstat(newObject); err == nil {
+		return fmt.Errorf("rename '%s' to '%s', the path already exists", oldPath, newPath)
+	}
+
+	logTrack.Info("rename object", "old", oldPath, "new", newPath)
+
+	if !t.options.DryRun {
+		err := os.MkdirAll(filepath.Dir(newObject), 0750)
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.renameObject(oldPath, newPath)
+}
+
+// transform applies the manifest rules to the src contents of path, symbolic
+// links are kept as they are.
+func (t *Update) transform(path string, mode uint16, contents []byte) ([]byte, error) {
+	if mode == uint16(git.FilemodeLink) {
+		return contents, nil
+	}
+	return transform.Apply(t.manifest, path, contents)
+}
+
+// lookupBlob finds a baseline blob. The blob is missing in src when it was
+// copied from uncommitted changes, but dst has it since the copy.
+func (t *Update) lookupBlob(oid *git.Oid) (*git.Blob, error) {
+	blob, err := t.src.LookupBlob(oid)
+	if err == nil {
+		return blob, nil
+	}
+	return t.dst.LookupBlob(oid)
 }
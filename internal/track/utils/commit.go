@@ -2,12 +2,101 @@ package utils
 
 import (
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	git "github.com/libgit2/git2go/v34"
 )
 
-func Commit(repo *git.Repository, msg string, tree *git.Tree, parents ...*git.Commit) (*git.Oid, error) {
+// Commit creates a fhub-track commit in dst. The message records the src
+// remotes and the src commit of the tracked objects.
+func Commit(src, dst *git.Repository, hash *git.Oid, msg string, tree *git.Tree, parents ...*git.Commit) (*git.Oid, error) {
+	remotes, err := Remotes(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash != nil {
+		msg = fmt.Sprintf("hash:\n  %s\n%s", hash.String(), msg)
+	}
+
+	msg = fmt.Sprintf(
+		"fhub-track\n\nrepo:\n  %s\n%s",
+		strings.Join(remotes, "\n  "), msg,
+	)
+
+	signature, err := dst.DefaultSignature()
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := dst.CreateCommit("HEAD", signature, signature, msg, tree, parents...)
+	if err != nil {
+		return nil, err
+	}
+
+	return oid, nil
+}
+
+// ReplayCommit creates a dst commit with the author and message of a src
+// commit, and a fhub-track trailer with its hash. refname is updated to the
+// commit unless it is empty.
+func ReplayCommit(dst *git.Repository, refname string, commitSrc *git.Commit, tree *git.Tree, parents ...*git.Commit) (*git.Oid, error) {
+	msg := fmt.Sprintf("%s\n\nfhub-track: %s\n", strings.TrimSpace(commitSrc.Message()), commitSrc.Id().String())
+
+	committer, err := dst.DefaultSignature()
+	if err != nil {
+		return nil, err
+	}
+
+	return dst.CreateCommit(refname, commitSrc.Author(), committer, msg, tree, parents...)
+}
+
+// Touches reports whether commit changed any of paths, files or folders,
+// since its first parent.
+func Touches(repo *git.Repository, commit *git.Commit, paths []string) (bool, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return false, err
+	}
+
+	var parentTree *git.Tree
+	if commit.ParentCount() > 0 {
+		parentTree, err = commit.Parent(0).Tree()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	pathspec := paths
+	for _, path := range paths {
+		if path == "." {
+			pathspec = nil
+			break
+		}
+	}
+
+	diff, err := repo.DiffTreeToTree(parentTree, tree, &git.DiffOptions{
+		Flags:    git.DiffDisablePathspecMatch,
+		Pathspec: pathspec,
+	})
+	if err != nil {
+		return false, err
+	}
+	defer diff.Free()
+
+	deltas, err := diff.NumDeltas()
+	if err != nil {
+		return false, err
+	}
+
+	return deltas > 0, nil
+}
+
+// Remotes lists the remotes of repo as "<name>:<url>".
+func Remotes(repo *git.Repository) ([]string, error) {
 	remotesName, err := repo.Remotes.List()
 	if err != nil {
 		return nil, err
@@ -23,27 +112,133 @@ func Commit(repo *git.Repository, msg string, tree *git.Tree, parents ...*git.Co
 		remotes = append(remotes, fmt.Sprintf("%s:%s", remoteName, remote.Url()))
 	}
 
-	head, err := repo.Head()
+	return remotes, nil
+}
+
+// RepoUrl identifies repo by the url of its first remote, or by its path
+// when it has no remotes.
+func RepoUrl(repo *git.Repository) (string, error) {
+	remotesName, err := repo.Remotes.List()
+	if err != nil {
+		return "", err
+	}
+
+	if len(remotesName) == 0 {
+		if repo.IsBare() {
+			return filepath.Clean(repo.Path()), nil
+		}
+		return filepath.Clean(repo.Workdir()), nil
+	}
+
+	remote, err := repo.Remotes.Lookup(remotesName[0])
+	if err != nil {
+		return "", err
+	}
+
+	return remote.Url(), nil
+}
+
+// scpLike matches the "user@host:path" urls of ssh.
+var scpLike = regexp.MustCompile(`^[\w.-]+@[\w.-]+:`)
+
+// IsURL reports whether src is a git url rather than a local path.
+func IsURL(src string) bool {
+	return strings.Contains(src, "://") || scpLike.MatchString(src)
+}
+
+// RepoName is the host and the path of url, without the scheme, the user and
+// the ".git" suffix.
+func RepoName(url string) string {
+	name := url
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+len("://"):]
+	}
+	if i := strings.Index(name, "@"); i >= 0 && i < strings.IndexAny(name+"/", "/:") {
+		name = name[i+1:]
+	}
+	name = strings.ReplaceAll(name, ":", "/")
+	return strings.TrimPrefix(strings.TrimSuffix(filepath.Clean("/"+name), ".git"), "/")
+}
+
+// SameRepo reports whether the urls of RepoUrl may be of the same repository.
+// Urls are compared by RepoName, so ssh and https clones match. Local paths
+// differ between clones, a path matches any url.
+func SameRepo(a, b string) bool {
+	if !IsURL(a) || !IsURL(b) {
+		return true
+	}
+	return RepoName(a) == RepoName(b)
+}
+
+// RevCommit resolves rev, a branch, tag or commit hash, to a commit.
+func RevCommit(repo *git.Repository, rev string) (*git.Commit, error) {
+	object, err := repo.RevparseSingle(rev)
 	if err != nil {
 		return nil, err
 	}
 
-	msg = fmt.Sprintf(
-		"fhub-track\n\nrepo:\n  %s\nhash:\n  %s\n%s",
-		strings.Join(remotes, "\n  "), head.Target().String(), msg,
-	)
+	object, err = object.Peel(git.ObjectCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.AsCommit()
+}
+
+// DateFormats are the date layouts accepted by ParseDate.
+var DateFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range DateFormats {
+		date, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date '%s'", value)
+}
 
-	signature, err := repo.DefaultSignature()
+// DateCommit finds the newest commit of the repo HEAD history committed until
+// date.
+func DateCommit(repo *git.Repository, date time.Time) (*git.Commit, error) {
+	walk, err := repo.Walk()
 	if err != nil {
 		return nil, err
 	}
+	defer walk.Free()
 
-	oid, err := repo.CreateCommit("HEAD", signature, signature, msg, tree, parents...)
+	walk.Sorting(git.SortTime)
+	err = walk.PushHead()
 	if err != nil {
 		return nil, err
 	}
 
-	return oid, nil
+	var found *git.Commit
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		if commit.Committer().When.After(date) {
+			return true
+		}
+		found = commit
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no commit until %s", date.Format(time.RFC3339))
+	}
+
+	return found, nil
+}
+
+func HeadCommit(repo *git.Repository) (*git.Commit, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.LookupCommit(head.Target())
 }
 
 func CommitParents(commit *git.Commit) []*git.Commit {
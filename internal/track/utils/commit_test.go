@@ -0,0 +1,26 @@
+package utils
+
+import "testing"
+
+func TestSameRepo(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"https://github.com/galgotech/fhub-track", "https://github.com/galgotech/fhub-track", true},
+		{"https://github.com/galgotech/fhub-track", "https://github.com/galgotech/fhub-track.git", true},
+		{"https://github.com/galgotech/fhub-track/", "git@github.com:galgotech/fhub-track.git", true},
+		{"ssh://git@github.com/galgotech/fhub-track.git", "git@github.com:galgotech/fhub-track", true},
+		{"https://github.com/galgotech/fhub-track", "https://github.com/galgotech/other", false},
+		{"https://github.com/galgotech/fhub-track", "https://gitlab.com/galgotech/fhub-track", false},
+		{"/home/user/fhub-track", "https://github.com/galgotech/fhub-track", true},
+		{"/home/user/fhub-track", "/ci/build/fhub-track", true},
+	}
+
+	for _, test := range tests {
+		same := SameRepo(test.a, test.b)
+		if same != test.same {
+			t.Errorf("SameRepo(%q, %q) = %v, want %v", test.a, test.b, same, test.same)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// WriteObject writes the blob contents to path with the git file mode, a
+// symbolic link is created pointing to the contents.
+func WriteObject(path string, contents []byte, mode git.Filemode) error {
+	err := os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err == nil && (mode == git.FilemodeLink || info.Mode()&fs.ModeSymlink != 0) {
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if mode == git.FilemodeLink {
+		return os.Symlink(string(contents), path)
+	}
+
+	perm := fs.FileMode(0644)
+	if mode == git.FilemodeBlobExecutable {
+		perm = 0755
+	}
+
+	err = os.WriteFile(path, contents, perm)
+	if err != nil {
+		return err
+	}
+
+	// WriteFile keeps the permissions of an existing file
+	return os.Chmod(path, perm)
+}
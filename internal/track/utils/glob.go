@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// IsGlob reports whether object has shell pattern characters.
+func IsGlob(object string) bool {
+	return strings.ContainsAny(object, "*?[")
+}
+
+// SplitGlob splits pattern in the folder before the first segment with shell
+// pattern characters and the pattern relative to that folder.
+func SplitGlob(pattern string) (string, string) {
+	segments := strings.Split(path.Clean(pattern), "/")
+	for i, segment := range segments {
+		if IsGlob(segment) {
+			return path.Join(append([]string{"."}, segments[:i]...)...), strings.Join(segments[i:], "/")
+		}
+	}
+	return path.Clean(pattern), ""
+}
+
+// MatchGlob reports whether name matches the shell pattern. A "**" segment
+// matches any number of folders.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
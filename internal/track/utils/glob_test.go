@@ -0,0 +1,58 @@
+package utils
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"cmd/*.go", "cmd/track/main.go", false},
+		{"**", "cmd/track/main.go", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/track/main.go", true},
+		{"**/*.go", "cmd/track/main.txt", false},
+		{"cmd/**/main.go", "cmd/main.go", true},
+		{"cmd/**/main.go", "cmd/track/update/main.go", true},
+		{"cmd/**/main.go", "internal/main.go", false},
+		{"cmd/**", "cmd", true},
+		{"*/**", "main.go", true},
+		{"*/**/*", "main.go", false},
+		{"*/**/*", "cmd/track/main.go", true},
+		{"[a-c]md/main.go", "cmd/main.go", true},
+		{"[", "[", false},
+	}
+
+	for _, test := range tests {
+		match := MatchGlob(test.pattern, test.name)
+		if match != test.match {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", test.pattern, test.name, match, test.match)
+		}
+	}
+}
+
+func TestSplitGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dir     string
+		rel     string
+	}{
+		{"cmd/main.go", "cmd/main.go", ""},
+		{"*.go", ".", "*.go"},
+		{"cmd/*.go", "cmd", "*.go"},
+		{"cmd/track/**/*.go", "cmd/track", "**/*.go"},
+		{"./cmd//track/", "cmd/track", ""},
+		{"cmd/ma?n.go", "cmd", "ma?n.go"},
+	}
+
+	for _, test := range tests {
+		dir, rel := SplitGlob(test.pattern)
+		if dir != test.dir || rel != test.rel {
+			t.Errorf("SplitGlob(%q) = %q, %q, want %q, %q", test.pattern, dir, rel, test.dir, test.rel)
+		}
+	}
+}
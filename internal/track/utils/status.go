@@ -27,3 +27,36 @@ func Status(repo *git.Repository) (*git.StatusList, error) {
 
 	return status, nil
 }
+
+// Modified lists the files with uncommitted changes in the index or in the
+// work tree. Untracked and ignored files are not included.
+func Modified(repo *git.Repository, pathspec ...string) ([]string, error) {
+	status, err := repo.StatusList(&git.StatusOptions{
+		Show:     git.StatusShowIndexAndWorkdir,
+		Pathspec: pathspec,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := status.EntryCount()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for i := 0; i < c; i++ {
+		entry, err := status.ByIndex(i)
+		if err != nil {
+			return nil, err
+		}
+
+		path := entry.HeadToIndex.NewFile.Path
+		if path == "" {
+			path = entry.IndexToWorkdir.NewFile.Path
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
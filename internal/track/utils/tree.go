@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+
+	git "github.com/libgit2/git2go/v34"
+)
+
+// TreeObjects lists the blobs of object, a file or a folder, in tree.
+func TreeObjects(tree *git.Tree, object string) ([]string, error) {
+	object = filepath.Clean(object)
+
+	subTree := tree
+	if object != "." {
+		entry, err := tree.EntryByPath(object)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Type == git.ObjectBlob {
+			return []string{object}, nil
+		}
+		if entry.Type != git.ObjectTree {
+			return nil, fmt.Errorf("object '%s' is not a file or folder", object)
+		}
+
+		subTree, err = tree.Owner().LookupTree(entry.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	allObjects := []string{}
+	err := subTree.Walk(func(root string, entry *git.TreeEntry) error {
+		if entry.Type == git.ObjectBlob {
+			allObjects = append(allObjects, filepath.Join(object, root, entry.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return allObjects, nil
+}
+
+// CopyBlob writes the src blob oid in the dst object database.
+func CopyBlob(src, dst *git.Repository, oid *git.Oid) error {
+	if _, err := dst.LookupBlob(oid); err == nil {
+		return nil
+	}
+
+	blob, err := src.LookupBlob(oid)
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.CreateBlobFromBuffer(blob.Contents())
+	return err
+}